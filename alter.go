@@ -0,0 +1,357 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TypeChange describes changing a column's type in place, which is
+// considered unsafe to do at scale and so is only done when AlterColumnType is
+// called for the column. Using is the expression that converts an old value
+// into the new type (it defaults to the column itself) and FailIf is a
+// predicate matching the rows that would not survive the conversion.
+type TypeChange struct {
+	Using  string
+	FailIf string
+}
+
+var charLengthRegexp = regexp.MustCompile(`(?i)^\s*(?:VAR)?CHAR(?:ACTER)?(?:\s+VARYING)?\s*\(\s*(\d+)\s*\)\s*$`)
+
+// AlterColumnType returns the queries that change the type of a column in
+// tableName to the type it has in wantTables.
+//
+// SQLite does not support altering a column's type, so the table is rebuilt
+// from wantTables.CreateTable and its rows are copied over with a CAST (see
+// sqliteRebuildTable). This must be run with foreign keys turned off. db is
+// only used on SQLite, to look up the table being rebuilt.
+func AlterColumnType(db DB, dialect string, wantTables WantTables, tableName [2]string, columnName string, change TypeChange) (querylist []string, err error) {
+	columns, err := wantTables.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	column, ok := columns[columnName]
+	if !ok {
+		return nil, fmt.Errorf("column %s not found in table %s", columnName, tableName[1])
+	}
+	if column.ColumnType == "" {
+		return nil, fmt.Errorf("column %s has no type to change to", columnName)
+	}
+	using := change.Using
+	if using == "" {
+		using = quoteIdentifier(dialect, columnName)
+	}
+	table := qualifiedName(dialect, tableName[0], tableName[1])
+	switch dialect {
+	case "postgres":
+		query := "ALTER TABLE " + table + " ALTER COLUMN " + quoteIdentifier(dialect, columnName) + " TYPE " + column.ColumnType
+		if column.Collation.Valid {
+			query += " " + collationClause(dialect, column.Collation.String)
+		}
+		if change.Using != "" {
+			query += " USING " + change.Using
+		}
+		return []string{query}, nil
+	case "mysql":
+		if change.Using != "" {
+			return nil, fmt.Errorf("mysql: MODIFY COLUMN does not support a USING expression")
+		}
+		return []string{"ALTER TABLE " + table + " MODIFY COLUMN " + modifyColumnDefinition(column)}, nil
+	case "sqlite3":
+		return sqliteRebuildTable(db, wantTables, tableName, map[string]string{
+			columnName: "CAST(" + using + " AS " + column.ColumnType + ")",
+		})
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+// modifyColumnDefinition returns the column definition of a MySQL MODIFY
+// COLUMN. MODIFY replaces the whole definition, so unlike columnDefinition it
+// has to keep AUTO_INCREMENT. PRIMARY KEY and UNIQUE are left out because the
+// column already has them, and repeating them would add them a second time.
+func modifyColumnDefinition(column Column) string {
+	onUpdate, comment := column.OnUpdateCurrentTimestamp, column.Comment
+	column.IsPrimaryKey, column.IsUnique = false, false
+	column.OnUpdateCurrentTimestamp, column.Comment = sql.NullBool{}, sql.NullString{}
+	definition := columnDefinition("mysql", column)
+	if column.Autoincrement == autoincrementAutoIncrement {
+		definition += " AUTO_INCREMENT"
+	}
+	if onUpdate.Valid && onUpdate.Bool {
+		definition += " ON UPDATE CURRENT_TIMESTAMP"
+	}
+	if comment.Valid {
+		definition += " COMMENT " + quoteLiteral(comment.String)
+	}
+	return definition
+}
+
+var createObjectRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+((?:UNIQUE\s+)?INDEX|TRIGGER)\s+(?:IF\s+NOT\s+EXISTS\s+)?`)
+
+// sqliteRebuildTable returns the queries that recreate tableName from
+// wantTables.CreateTable and copy its rows over. Only the columns that the
+// table already has are copied, and selectExprs overrides the value copied
+// into a column. Dropping the old table drops its indexes and triggers too, so
+// they are recreated from sqlite_master unless wantTables.CreateTable already
+// created them.
+func sqliteRebuildTable(db DB, wantTables WantTables, tableName [2]string, selectExprs map[string]string) (querylist []string, err error) {
+	const dialect = "sqlite3"
+	columns, err := wantTables.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	createQuerylist, _, err := wantTables.CreateTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(createQuerylist) == 0 {
+		return nil, fmt.Errorf("no CREATE TABLE query for table %s", tableName[1])
+	}
+	gotColumns, err := getColumnNames(db, dialect, tableName)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name, column := range columns {
+		if column.GeneratedExpr.Valid || !gotColumns[name] {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	insertColumns := make([]string, len(names))
+	selectColumns := make([]string, len(names))
+	for i, name := range names {
+		insertColumns[i] = quoteIdentifier(dialect, name)
+		selectColumns[i] = insertColumns[i]
+		if expr, ok := selectExprs[name]; ok {
+			selectColumns[i] = expr
+		}
+	}
+	query := "SELECT sql FROM sqlite_master WHERE type IN ('index', 'trigger') AND tbl_name = ? AND sql IS NOT NULL ORDER BY type, name"
+	rows, err := db.Query(query, tableName[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", query, err)
+	}
+	defer rows.Close()
+	var objects []string
+	for rows.Next() {
+		var object string
+		err = rows.Scan(&object)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, createObjectRegexp.ReplaceAllString(object, "CREATE $1 IF NOT EXISTS "))
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	table := quoteIdentifier(dialect, tableName[1])
+	oldTable := quoteIdentifier(dialect, tableName[1]+"__old")
+	querylist = append(querylist,
+		"PRAGMA legacy_alter_table = ON",
+		"ALTER TABLE "+table+" RENAME TO "+oldTable,
+		createQuerylist[0],
+		"INSERT INTO "+table+" ("+strings.Join(insertColumns, ", ")+") SELECT "+strings.Join(selectColumns, ", ")+" FROM "+oldTable,
+		"DROP TABLE "+oldTable,
+	)
+	querylist = append(querylist, createQuerylist[1:]...)
+	querylist = append(querylist, objects...)
+	querylist = append(querylist, "PRAGMA legacy_alter_table = OFF")
+	return querylist, nil
+}
+
+// CountTypeChangeFailures counts the rows in tableName that would fail to be
+// converted by AlterColumnType. If change.FailIf is empty it is derived from
+// the new type where possible (e.g. values too long for a VARCHAR(n)).
+func CountTypeChangeFailures(db DB, dialect string, wantTables WantTables, tableName [2]string, columnName string, change TypeChange) (count int64, err error) {
+	columns, err := wantTables.GetColumns(tableName)
+	if err != nil {
+		return 0, err
+	}
+	column, ok := columns[columnName]
+	if !ok {
+		return 0, fmt.Errorf("column %s not found in table %s", columnName, tableName[1])
+	}
+	query, err := typeChangeFailuresQuery(dialect, column, change)
+	if err != nil {
+		return 0, err
+	}
+	err = db.QueryRow(query).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func typeChangeFailuresQuery(dialect string, column Column, change TypeChange) (string, error) {
+	failIf := change.FailIf
+	if failIf == "" {
+		matches := charLengthRegexp.FindStringSubmatch(column.ColumnType)
+		if matches == nil {
+			return "", fmt.Errorf("cannot work out which values of %s would fail conversion to %s, provide a FailIf predicate", column.ColumnName, column.ColumnType)
+		}
+		value := change.Using
+		if value == "" {
+			value = quoteIdentifier(dialect, column.ColumnName)
+		}
+		if dialect == "sqlite3" {
+			failIf = "LENGTH(" + value + ") > " + matches[1]
+		} else {
+			failIf = "CHAR_LENGTH(" + value + ") > " + matches[1]
+		}
+	}
+	return "SELECT COUNT(*) FROM " + qualifiedName(dialect, column.TableSchema, column.TableName) + " WHERE " + failIf, nil
+}
+
+// NotNullBackfill describes how EnsureNotNull makes a nullable column NOT
+// NULL. Before the constraint is added, existing NULLs are replaced with Value
+// (an SQL expression) BatchSize rows at a time.
+type NotNullBackfill struct {
+	Value     string
+	BatchSize int
+}
+
+// BackfillNotNull replaces the NULLs in a column with backfill.Value in
+// batches, so that no single UPDATE holds its locks for too long. It returns
+// the number of NULLs replaced, and fails if backfill.Value is itself NULL for
+// any of the rows.
+func BackfillNotNull(db DB, dialect string, tableName [2]string, columnName string, backfill NotNullBackfill) (rowsAffected int64, err error) {
+	if backfill.Value == "" {
+		return 0, fmt.Errorf("no backfill value for column %s", columnName)
+	}
+	if strings.EqualFold(strings.TrimSpace(backfill.Value), "NULL") {
+		return 0, fmt.Errorf("backfill value for column %s is NULL", columnName)
+	}
+	batchSize := backfill.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	table := qualifiedName(dialect, tableName[0], tableName[1])
+	column := quoteIdentifier(dialect, columnName)
+	countQuery := "SELECT COUNT(*) FROM " + table + " WHERE " + column + " IS NULL"
+	var nullCount int64
+	err = db.QueryRow(countQuery).Scan(&nullCount)
+	if err != nil {
+		return 0, err
+	}
+	var query string
+	switch dialect {
+	case "postgres":
+		query = fmt.Sprintf("UPDATE %[1]s SET %[2]s = %[3]s WHERE ctid IN (SELECT ctid FROM %[1]s WHERE %[2]s IS NULL LIMIT %[4]d)", table, column, backfill.Value, batchSize)
+	case "mysql":
+		query = fmt.Sprintf("UPDATE %[1]s SET %[2]s = %[3]s WHERE %[2]s IS NULL LIMIT %[4]d", table, column, backfill.Value, batchSize)
+	case "sqlite3":
+		query = fmt.Sprintf("UPDATE %[1]s SET %[2]s = %[3]s WHERE rowid IN (SELECT rowid FROM %[1]s WHERE %[2]s IS NULL LIMIT %[4]d)", table, column, backfill.Value, batchSize)
+	default:
+		return 0, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	// An expression that evaluates to NULL would keep matching the same rows
+	// forever, so never run more batches than there were NULLs to begin with.
+	for i := int64(0); i <= nullCount/int64(batchSize); i++ {
+		result, err := db.Exec(query)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if n < int64(batchSize) {
+			break
+		}
+	}
+	// Rows set to NULL again are counted as affected, so what was replaced
+	// is worked out from the NULLs that are left.
+	var remaining int64
+	err = db.QueryRow(countQuery).Scan(&remaining)
+	if err != nil {
+		return 0, err
+	}
+	if remaining > 0 {
+		return nullCount - remaining, fmt.Errorf("backfill value for column %s is NULL for %d rows", columnName, remaining)
+	}
+	return nullCount, nil
+}
+
+// SetNotNull returns the queries that make a column NOT NULL once it no
+// longer contains any NULLs.
+//
+// Postgres validates a NOT VALID CHECK (col IS NOT NULL) constraint first,
+// which only takes a SHARE UPDATE EXCLUSIVE lock and lets SET NOT NULL skip
+// its full table scan. The constraint is only added if it does not exist yet,
+// so the queries can be run again after being interrupted. SQLite has no way of adding NOT NULL to an existing
+// column so the table is rebuilt from wantTables.CreateTable.
+func SetNotNull(db DB, dialect string, wantTables WantTables, tableName [2]string, columnName string) (querylist []string, err error) {
+	columns, err := wantTables.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	column, ok := columns[columnName]
+	if !ok {
+		return nil, fmt.Errorf("column %s not found in table %s", columnName, tableName[1])
+	}
+	table := qualifiedName(dialect, tableName[0], tableName[1])
+	switch dialect {
+	case "postgres":
+		checkName := quoteIdentifier(dialect, notNullCheckName(tableName[1], columnName))
+		col := quoteIdentifier(dialect, columnName)
+		return []string{
+			"DO $$ BEGIN ALTER TABLE " + table + " ADD CONSTRAINT " + checkName + " CHECK (" + col + " IS NOT NULL) NOT VALID; EXCEPTION WHEN duplicate_object THEN NULL; END $$",
+			"ALTER TABLE " + table + " VALIDATE CONSTRAINT " + checkName,
+			"ALTER TABLE " + table + " ALTER COLUMN " + col + " SET NOT NULL",
+			"ALTER TABLE " + table + " DROP CONSTRAINT " + checkName,
+		}, nil
+	case "mysql":
+		column.IsNotNull = true
+		return []string{"ALTER TABLE " + table + " MODIFY COLUMN " + modifyColumnDefinition(column)}, nil
+	case "sqlite3":
+		return sqliteRebuildTable(db, wantTables, tableName, nil)
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+// notNullCheckName names the CHECK constraint that SetNotNull validates on
+// Postgres. Postgres cuts names down to 63 bytes, so a longer name is cut
+// down and suffixed with a hash of the whole name to keep it apart from the
+// names it would otherwise be cut into.
+func notNullCheckName(tableName string, columnName string) string {
+	name := tableName + "_" + columnName + "_not_null"
+	if len(name) <= 63 {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	n := 54
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+	return name[:n] + "_" + fmt.Sprintf("%08x", h.Sum32())
+}
+
+// EnsureNotNull backfills the NULLs in a column and then makes it NOT NULL,
+// resolving an IsNotNull mismatch without a hand-written resolver.
+func EnsureNotNull(db DB, dialect string, wantTables WantTables, tableName [2]string, columnName string, backfill NotNullBackfill) error {
+	_, err := BackfillNotNull(db, dialect, tableName, columnName, backfill)
+	if err != nil {
+		return err
+	}
+	querylist, err := SetNotNull(db, dialect, wantTables, tableName, columnName)
+	if err != nil {
+		return err
+	}
+	for _, query := range querylist {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package metadata
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestAlterColumnType(t *testing.T) {
+	actor := [2]string{"", "actor"}
+	wantTables := mockTables{
+		columns: map[[2]string]map[string]Column{
+			actor: {
+				"actor_id":   {TableName: "actor", ColumnName: "actor_id", ColumnType: "INTEGER", IsPrimaryKey: true},
+				"first_name": {TableName: "actor", ColumnName: "first_name", ColumnType: "VARCHAR(5)", IsNotNull: true},
+				"last_name":  {TableName: "actor", ColumnName: "last_name", ColumnType: "TEXT"},
+			},
+		},
+		createTable: map[[2]string][]string{
+			actor: {
+				"CREATE TABLE actor (actor_id INTEGER PRIMARY KEY, first_name VARCHAR(5) NOT NULL, last_name TEXT)",
+				"CREATE INDEX actor_first_name_idx ON actor (first_name)",
+			},
+		},
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		is := testutil.New(t)
+		querylist, err := AlterColumnType(nil, "postgres", wantTables, actor, "first_name", TypeChange{Using: "TRIM(first_name)"})
+		is.NoErr(err)
+		is.Equal([]string{"ALTER TABLE actor ALTER COLUMN first_name TYPE VARCHAR(5) USING TRIM(first_name)"}, querylist)
+	})
+
+	t.Run("mysql", func(t *testing.T) {
+		is := testutil.New(t)
+		querylist, err := AlterColumnType(nil, "mysql", wantTables, actor, "first_name", TypeChange{})
+		is.NoErr(err)
+		is.Equal([]string{"ALTER TABLE actor MODIFY COLUMN first_name VARCHAR(5) NOT NULL"}, querylist)
+		_, err = AlterColumnType(nil, "mysql", wantTables, actor, "first_name", TypeChange{Using: "TRIM(first_name)"})
+		is.True(err != nil)
+	})
+
+	t.Run("mysql AUTO_INCREMENT primary key", func(t *testing.T) {
+		is := testutil.New(t)
+		film := [2]string{"db", "film"}
+		wantTables := mockTables{
+			columns: map[[2]string]map[string]Column{
+				film: {
+					"film_id": {TableSchema: "db", TableName: "film", ColumnName: "film_id", ColumnType: "BIGINT", IsNotNull: true, IsPrimaryKey: true, Autoincrement: autoincrementAutoIncrement},
+					"title":   {TableSchema: "db", TableName: "film", ColumnName: "title", ColumnType: "VARCHAR(255)", IsUnique: true, Comment: sql.NullString{String: "title", Valid: true}},
+				},
+			},
+		}
+		querylist, err := AlterColumnType(nil, "mysql", wantTables, film, "film_id", TypeChange{})
+		is.NoErr(err)
+		is.Equal([]string{"ALTER TABLE db.film MODIFY COLUMN film_id BIGINT NOT NULL AUTO_INCREMENT"}, querylist)
+		querylist, err = SetNotNull(nil, "mysql", wantTables, film, "title")
+		is.NoErr(err)
+		is.Equal([]string{"ALTER TABLE db.film MODIFY COLUMN title VARCHAR(255) NOT NULL COMMENT 'title'"}, querylist)
+	})
+
+	t.Run("sqlite3", func(t *testing.T) {
+		is := testutil.New(t)
+		db, err := sql.Open("sqlite3", ":memory:")
+		is.NoErr(err)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		_, err = db.Exec("CREATE TABLE actor (actor_id INTEGER PRIMARY KEY, first_name TEXT NOT NULL)")
+		is.NoErr(err)
+		_, err = db.Exec("CREATE INDEX actor_first_name_idx ON actor (first_name)")
+		is.NoErr(err)
+		_, err = db.Exec("CREATE INDEX actor_first_name_upper_idx ON actor (UPPER(first_name))")
+		is.NoErr(err)
+		_, err = db.Exec("CREATE TABLE actor_log (first_name TEXT)")
+		is.NoErr(err)
+		_, err = db.Exec("CREATE TRIGGER actor_log_insert AFTER INSERT ON actor BEGIN INSERT INTO actor_log VALUES (NEW.first_name); END")
+		is.NoErr(err)
+		_, err = db.Exec("INSERT INTO actor (first_name) VALUES ('alice'), ('bartholomew'), ('carol')")
+		is.NoErr(err)
+
+		count, err := CountTypeChangeFailures(db, "sqlite3", wantTables, actor, "first_name", TypeChange{})
+		is.NoErr(err)
+		is.Equal(int64(1), count)
+
+		querylist, err := AlterColumnType(db, "sqlite3", wantTables, actor, "first_name", TypeChange{})
+		is.NoErr(err)
+		for _, query := range querylist {
+			_, err = db.Exec(query)
+			is.NoErr(err)
+		}
+		var columnType string
+		err = db.QueryRow("SELECT type FROM pragma_table_info('actor') WHERE name = 'first_name'").Scan(&columnType)
+		is.NoErr(err)
+		is.Equal("VARCHAR(5)", columnType)
+		var rowCount int
+		err = db.QueryRow("SELECT COUNT(*) FROM actor").Scan(&rowCount)
+		is.NoErr(err)
+		is.Equal(3, rowCount)
+		var names []string
+		rows, err := db.Query("SELECT name FROM sqlite_master WHERE tbl_name = 'actor' AND type IN ('index', 'trigger') ORDER BY name")
+		is.NoErr(err)
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			is.NoErr(rows.Scan(&name))
+			names = append(names, name)
+		}
+		is.Equal([]string{"actor_first_name_idx", "actor_first_name_upper_idx", "actor_log_insert"}, names)
+		_, err = db.Exec("INSERT INTO actor (first_name) VALUES ('dave')")
+		is.NoErr(err)
+		err = db.QueryRow("SELECT COUNT(*) FROM actor_log").Scan(&rowCount)
+		is.NoErr(err)
+		is.Equal(4, rowCount)
+	})
+
+	t.Run("no FailIf", func(t *testing.T) {
+		is := testutil.New(t)
+		_, err := typeChangeFailuresQuery("postgres", Column{TableName: "film", ColumnName: "rating", ColumnType: "mpaa_rating"}, TypeChange{})
+		is.True(err != nil)
+	})
+}
+
+func TestEnsureNotNull(t *testing.T) {
+	customer := [2]string{"", "customer"}
+	wantTables := mockTables{
+		columns: map[[2]string]map[string]Column{
+			customer: {
+				"customer_id": {TableName: "customer", ColumnName: "customer_id", ColumnType: "INTEGER", IsPrimaryKey: true},
+				"email":       {TableName: "customer", ColumnName: "email", ColumnType: "TEXT", IsNotNull: true},
+			},
+		},
+		createTable: map[[2]string][]string{
+			customer: {"CREATE TABLE customer (customer_id INTEGER PRIMARY KEY, email TEXT NOT NULL)"},
+		},
+	}
+
+	t.Run("postgres", func(t *testing.T) {
+		is := testutil.New(t)
+		querylist, err := SetNotNull(nil, "postgres", wantTables, customer, "email")
+		is.NoErr(err)
+		is.Equal([]string{
+			"DO $$ BEGIN ALTER TABLE customer ADD CONSTRAINT customer_email_not_null CHECK (email IS NOT NULL) NOT VALID; EXCEPTION WHEN duplicate_object THEN NULL; END $$",
+			"ALTER TABLE customer VALIDATE CONSTRAINT customer_email_not_null",
+			"ALTER TABLE customer ALTER COLUMN email SET NOT NULL",
+			"ALTER TABLE customer DROP CONSTRAINT customer_email_not_null",
+		}, querylist)
+		name := notNullCheckName(strings.Repeat("t", 40), strings.Repeat("c", 40))
+		is.Equal(63, len(name))
+		is.True(name != notNullCheckName(strings.Repeat("t", 40), strings.Repeat("c", 41)))
+		is.Equal("customer_email_not_null", notNullCheckName("customer", "email"))
+	})
+
+	t.Run("sqlite3", func(t *testing.T) {
+		is := testutil.New(t)
+		db, err := sql.Open("sqlite3", ":memory:")
+		is.NoErr(err)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		_, err = db.Exec("CREATE TABLE customer (customer_id INTEGER PRIMARY KEY, email TEXT)")
+		is.NoErr(err)
+		_, err = db.Exec("INSERT INTO customer (email) VALUES ('a@example.com'), (NULL), (NULL), (NULL)")
+		is.NoErr(err)
+
+		_, err = BackfillNotNull(db, "sqlite3", customer, "email", NotNullBackfill{Value: "NULL", BatchSize: 2})
+		is.True(err != nil)
+		rowsAffected, err := BackfillNotNull(db, "sqlite3", customer, "email", NotNullBackfill{Value: "CASE WHEN customer_id = 2 THEN 'customer2@example.com' END", BatchSize: 2})
+		is.True(err != nil)
+		is.Equal(int64(1), rowsAffected)
+		rowsAffected, err = BackfillNotNull(db, "sqlite3", customer, "email", NotNullBackfill{Value: "'customer' || customer_id || '@example.com'", BatchSize: 1})
+		is.NoErr(err)
+		is.Equal(int64(2), rowsAffected)
+
+		err = EnsureNotNull(db, "sqlite3", wantTables, customer, "email", NotNullBackfill{Value: "'customer' || customer_id || '@example.com'", BatchSize: 2})
+		is.NoErr(err)
+		var notnull bool
+		err = db.QueryRow("SELECT \"notnull\" FROM pragma_table_info('customer') WHERE name = 'email'").Scan(&notnull)
+		is.NoErr(err)
+		is.True(notnull)
+		var email string
+		err = db.QueryRow("SELECT email FROM customer WHERE customer_id = 2").Scan(&email)
+		is.NoErr(err)
+		is.Equal("customer2@example.com", email)
+	})
+}
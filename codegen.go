@@ -0,0 +1,660 @@
+package metadata
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// columnNamesGetter is implemented by the GotTables that know the order in
+// which a table's columns were declared.
+type columnNamesGetter interface {
+	GetColumnNames(tableName [2]string) (columnNames []string, err error)
+}
+
+// GenerateStructs writes a Go table struct for every table in gotTables to w,
+// in the style of the table structs in mocks_test.go: an embedded tableinfo
+// carrying the table name, one field wrapper (numberfield, stringfield,
+// timefield, jsonfield, blobfield or booleanfield) per column, ddl tags for
+// everything tags can express and a Constraints method for the rest. The
+// package is expected to declare tableinfo and the field wrappers itself.
+func GenerateStructs(w io.Writer, dialect string, packageName string, gotTables GotTables) error {
+	tableNames, err := gotTables.GetTables()
+	if err != nil {
+		return err
+	}
+	primaryKeys := make(map[[2]string]string)
+	tables := make([]*structTable, 0, len(tableNames))
+	structNames := make(map[string]int)
+	for _, tableName := range tableNames {
+		table, err := getStructTable(gotTables, tableName)
+		if err != nil {
+			return fmt.Errorf("%v: %w", tableName, err)
+		}
+		tables = append(tables, table)
+		structNames[goName(tableName[1])]++
+		for _, constraint := range table.constraints {
+			if constraint.ConstraintType == "PRIMARY KEY" && len(constraint.Columns) == 1 {
+				primaryKeys[tableName] = constraint.Columns[0]
+			}
+		}
+		for _, column := range table.columns {
+			if column.IsPrimaryKey {
+				primaryKeys[tableName] = column.ColumnName
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString("package " + packageName + "\n")
+	receivers := make(map[string][2]string)
+	for _, table := range tables {
+		table.receiver = goName(table.tableName[1])
+		if structNames[table.receiver] > 1 && table.tableName[0] != "" {
+			table.receiver = goName(table.tableName[0] + "_" + table.tableName[1])
+		}
+		if other, ok := receivers[table.receiver]; ok {
+			return fmt.Errorf("tables %v and %v are both named %s in Go", other, table.tableName, table.receiver)
+		}
+		receivers[table.receiver] = table.tableName
+		table.primaryKeys = primaryKeys
+		buf.WriteString("\n")
+		err = table.writeStruct(dialect, buf)
+		if err != nil {
+			return fmt.Errorf("%v: %w", table.tableName, err)
+		}
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// structTable is a table being turned into a Go table struct.
+type structTable struct {
+	tableName   [2]string
+	receiver    string
+	columns     []Column
+	constraints []TableConstraint
+	indexes     []Index
+	primaryKeys map[[2]string]string // single column primary key of every table
+	tableTags   []string
+	columnTags  map[string][]string
+	constraint  []string // Constraints statements for every dialect
+	dialectOnly []string // Constraints statements for the generating dialect
+	indexGroups int
+}
+
+func getStructTable(gotTables GotTables, tableName [2]string) (*structTable, error) {
+	table := &structTable{tableName: tableName, columnTags: make(map[string][]string)}
+	columns, err := gotTables.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	var columnNames []string
+	if getter, ok := gotTables.(columnNamesGetter); ok {
+		columnNames, err = getter.GetColumnNames(tableName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Without a declared order, primary key columns go first and the
+		// rest are sorted by name.
+		for columnName := range columns {
+			columnNames = append(columnNames, columnName)
+		}
+		sort.Slice(columnNames, func(i, j int) bool {
+			if columns[columnNames[i]].IsPrimaryKey != columns[columnNames[j]].IsPrimaryKey {
+				return columns[columnNames[i]].IsPrimaryKey
+			}
+			return columnNames[i] < columnNames[j]
+		})
+	}
+	for _, columnName := range columnNames {
+		table.columns = append(table.columns, columns[columnName])
+	}
+	constraints, err := gotTables.GetConstraints(tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, constraint := range constraints {
+		table.constraints = append(table.constraints, constraint)
+	}
+	sort.Slice(table.constraints, func(i, j int) bool {
+		return table.constraints[i].ConstraintName < table.constraints[j].ConstraintName
+	})
+	indices, err := gotTables.GetIndices(tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indices {
+		table.indexes = append(table.indexes, index)
+	}
+	sort.Slice(table.indexes, func(i, j int) bool {
+		return table.indexes[i].IndexName < table.indexes[j].IndexName
+	})
+	return table, nil
+}
+
+func (t *structTable) writeStruct(dialect string, buf *bytes.Buffer) error {
+	fieldNames := make(map[string]string)
+	for _, column := range t.columns {
+		fieldName := goName(column.ColumnName)
+		if other, ok := fieldNames[fieldName]; ok {
+			return fmt.Errorf("columns %s and %s are both named %s in Go", other, column.ColumnName, fieldName)
+		}
+		fieldNames[fieldName] = column.ColumnName
+	}
+	t.tableTags = []string{"name=" + tagValue(t.tableName[1])}
+	if t.tableName[0] != "" {
+		t.dialectOnly = append(t.dialectOnly, "c.TableSchema("+strconv.Quote(t.tableName[0])+")")
+	}
+	for _, column := range t.columns {
+		t.addColumn(dialect, column)
+	}
+	for _, constraint := range t.constraints {
+		t.addConstraint(dialect, constraint)
+	}
+	for _, index := range t.indexes {
+		err := t.addIndex(index)
+		if err != nil {
+			return err
+		}
+	}
+	structName := "_" + t.receiver
+	buf.WriteString("type " + structName + " struct {\n")
+	buf.WriteString("\ttableinfo " + structTag(t.tableTags) + "\n")
+	for _, column := range t.columns {
+		buf.WriteString("\t" + goName(column.ColumnName) + " " + fieldWrapper(column.ColumnType))
+		if tags := t.columnTags[column.ColumnName]; len(tags) > 0 {
+			buf.WriteString(" " + structTag(tags))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	if len(t.constraint) == 0 && len(t.dialectOnly) == 0 {
+		return nil
+	}
+	buf.WriteString("\nfunc (" + t.receiver + " " + structName + ") Constraints(dialect string, c *C) {\n")
+	for _, statement := range t.constraint {
+		buf.WriteString("\t" + statement + "\n")
+	}
+	if len(t.dialectOnly) > 0 {
+		buf.WriteString("\tswitch dialect {\n\tcase " + strconv.Quote(dialect) + ":\n")
+		for _, statement := range t.dialectOnly {
+			buf.WriteString("\t\t" + statement + "\n")
+		}
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+func (t *structTable) field(columnName string) string {
+	return t.receiver + "." + goName(columnName)
+}
+
+func (t *structTable) addColumn(dialect string, column Column) {
+	var tags, constraints []string
+	name := column.ColumnName
+	wrapper := fieldWrapper(column.ColumnType)
+	// INTEGER PRIMARY KEY is what makes a column an alias of SQLite's rowid,
+	// so its type is always spelled out.
+	isRowid := column.Autoincrement == autoincrementRowid || column.Autoincrement == autoincrementRowidAutoincrement
+	if column.ColumnType != "" && (isRowid || normalizeType(column.ColumnType) != normalizeType(defaultFieldType(dialect, wrapper))) {
+		tags, constraints = addModifier(tags, constraints, "type", column.ColumnType, "c.Type")
+	}
+	if column.IsPrimaryKey {
+		tags = append(tags, "primarykey")
+	} else if column.IsNotNull {
+		tags = append(tags, "notnull")
+	}
+	if column.IsUnique {
+		tags = append(tags, "unique")
+	}
+	if column.GeneratedExpr.Valid {
+		kind := "virtual"
+		if column.GeneratedStored {
+			kind = "stored"
+		}
+		if v, ok := braced(column.GeneratedExpr.String); ok {
+			tags = append(tags, "generated={{"+v+"} "+kind+"}")
+		} else {
+			constraints = append(constraints, "c.Generated("+strconv.Quote(column.GeneratedExpr.String)+", "+strconv.FormatBool(column.GeneratedStored)+")")
+		}
+	}
+	isSerial := column.Autoincrement == autoincrementSerial && strings.HasPrefix(strings.ToLower(column.ColumnDefault.String), "nextval(")
+	if column.ColumnDefault.Valid && !isSerial {
+		tags, constraints = addModifier(tags, constraints, "default", column.ColumnDefault.String, "c.Default")
+	}
+	if column.Collation.Valid && column.Collation.String != "" {
+		tags, constraints = addModifier(tags, constraints, "collate", column.Collation.String, "c.Collate")
+	}
+	if column.ReferencesTable.Valid && !t.hasForeignKey(name) {
+		tags = append(tags, t.references(column.ReferencesSchema.String, column.ReferencesTable.String, column.ReferencesColumn.String, column.ReferencesOnUpdate, column.ReferencesOnDelete))
+	}
+	if column.Comment.Valid {
+		tags, constraints = addModifier(tags, constraints, "comment", column.Comment.String, "c.Comment")
+	}
+	t.columnTags[name] = append(t.columnTags[name], tags...)
+	if len(constraints) > 0 {
+		t.constraint = append(t.constraint, "c.Col("+t.field(name)+", "+strings.Join(constraints, ", ")+")")
+	}
+	if column.OnUpdateCurrentTimestamp.Valid && column.OnUpdateCurrentTimestamp.Bool {
+		t.constraint = append(t.constraint, "c.Col("+t.field(name)+", c.OnUpdateCurrentTimestamp)")
+	}
+	switch column.Autoincrement {
+	case autoincrementRowidAutoincrement:
+		t.dialectOnly = append(t.dialectOnly, "c.Col("+t.field(name)+", c.Autoincrement(AutoincrementSQLite))")
+	case autoincrementIdentity:
+		t.dialectOnly = append(t.dialectOnly, "c.Col("+t.field(name)+", c.Autoincrement(AutoincrementDefaultIdentity))")
+	case autoincrementAlwaysIdentity:
+		t.dialectOnly = append(t.dialectOnly, "c.Col("+t.field(name)+", c.Autoincrement(AutoincrementAlwaysIdentity))")
+	case autoincrementAutoIncrement:
+		t.dialectOnly = append(t.dialectOnly, "c.Col("+t.field(name)+", c.Autoincrement(AutoincrementMySQL))")
+	}
+}
+
+// addModifier adds name=value to a column's tags, or the equivalent column
+// constraint if value cannot be written in a tag.
+func addModifier(tags, constraints []string, name, value, constraint string) ([]string, []string) {
+	if v, ok := braced(value); ok {
+		if strings.ContainsAny(v, " \t\r\n") || strings.HasPrefix(v, "{") || v == "" {
+			v = "{" + v + "}"
+		}
+		return append(tags, name+"="+v), constraints
+	}
+	return tags, append(constraints, constraint+"("+strconv.Quote(value)+")")
+}
+
+// braced reports whether s can be written inside a ddl tag: its braces must
+// be balanced, and it cannot contain a backquote since struct tags are
+// written as raw strings.
+func braced(s string) (string, bool) {
+	if strings.Contains(s, "`") {
+		return "", false
+	}
+	var bracelevel int
+	for _, r := range s {
+		switch r {
+		case '{':
+			bracelevel++
+		case '}':
+			bracelevel--
+		}
+		if bracelevel < 0 {
+			return "", false
+		}
+	}
+	return s, bracelevel == 0
+}
+
+// tagValue brace-quotes s if it would otherwise be split by the tag lexer.
+func tagValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n{}") {
+		return "{" + s + "}"
+	}
+	return s
+}
+
+func structTag(tags []string) string {
+	return "`ddl:" + strconv.Quote(strings.Join(tags, " ")) + "`"
+}
+
+func (t *structTable) hasForeignKey(columnName string) bool {
+	for _, constraint := range t.constraints {
+		if constraint.ConstraintType == "FOREIGN KEY" && len(constraint.Columns) == 1 && constraint.Columns[0] == columnName {
+			return true
+		}
+	}
+	return false
+}
+
+// references returns the references tag modifier of a column, leaving out
+// the referenced column if it is the referenced table's primary key.
+func (t *structTable) references(refSchema, refTable, refColumn string, onUpdate, onDelete sql.NullString) string {
+	table := refTable
+	if refSchema != "" && refSchema != t.tableName[0] {
+		table = refSchema + "." + refTable
+	}
+	var modifiers []string
+	if refColumn != "" && refColumn != t.primaryKeys[[2]string{refSchema, refTable}] && refColumn != t.primaryKeys[[2]string{t.tableName[0], refTable}] {
+		modifiers = append(modifiers, "cols="+refColumn)
+	}
+	if s := refOptionTag(onUpdate.String); s != "" {
+		modifiers = append(modifiers, "onupdate="+s)
+	}
+	if s := refOptionTag(onDelete.String); s != "" {
+		modifiers = append(modifiers, "ondelete="+s)
+	}
+	if len(modifiers) == 0 {
+		return "references=" + tagValue(table)
+	}
+	return "references={" + table + " " + strings.Join(modifiers, " ") + "}"
+}
+
+// refOptionTag turns a referential action like SET NULL into its tag form
+// setnull. NO ACTION, being the default, is left out.
+func refOptionTag(opt string) string {
+	s := strings.ToLower(strings.ReplaceAll(opt, " ", ""))
+	if s == "noaction" {
+		return ""
+	}
+	return s
+}
+
+func (t *structTable) addConstraint(dialect string, constraint TableConstraint) {
+	switch constraint.ConstraintType {
+	case "PRIMARY KEY", "UNIQUE", "FOREIGN KEY":
+	case "CHECK":
+		t.constraint = append(t.constraint, "c.CheckString("+strconv.Quote(constraint.ConstraintName)+", "+strconv.Quote(constraint.CheckExpr.String)+")")
+		return
+	default:
+		return
+	}
+	name := constraint.ConstraintName
+	isDefaultName := name == "" || name == defaultConstraintName(dialect, t.tableName[1], constraint.ConstraintType, constraint.Columns)
+	if isDefaultName && len(constraint.Columns) == 1 {
+		column := t.column(constraint.Columns[0])
+		tags := t.columnTags[column.ColumnName]
+		switch constraint.ConstraintType {
+		case "PRIMARY KEY":
+			if !column.IsPrimaryKey {
+				t.columnTags[column.ColumnName] = append(removeTag(tags, "notnull"), "primarykey")
+			}
+		case "UNIQUE":
+			if !column.IsUnique {
+				t.columnTags[column.ColumnName] = append(tags, "unique")
+			}
+		case "FOREIGN KEY":
+			var refColumn string
+			if len(constraint.ReferencesColumns) == 1 {
+				refColumn = constraint.ReferencesColumns[0]
+			}
+			t.columnTags[column.ColumnName] = append(tags, t.references(constraint.ReferencesSchema, constraint.ReferencesTable, refColumn, constraint.OnUpdate, constraint.OnDelete))
+		}
+		return
+	}
+	if isDefaultName {
+		name = "."
+	}
+	modifiers := []string{tagValue(name), "cols=" + strings.Join(constraint.Columns, ",")}
+	if constraint.ConstraintType == "FOREIGN KEY" {
+		table := constraint.ReferencesTable
+		if constraint.ReferencesSchema != "" && constraint.ReferencesSchema != t.tableName[0] {
+			table = constraint.ReferencesSchema + "." + table
+		}
+		if len(constraint.ReferencesColumns) > 0 {
+			table = "{" + table + " cols=" + strings.Join(constraint.ReferencesColumns, ",") + "}"
+		}
+		modifiers = append(modifiers, "references="+table)
+		if s := refOptionTag(constraint.OnUpdate.String); s != "" {
+			modifiers = append(modifiers, "onupdate="+s)
+		}
+		if s := refOptionTag(constraint.OnDelete.String); s != "" {
+			modifiers = append(modifiers, "ondelete="+s)
+		}
+	}
+	modifier := strings.ToLower(strings.ReplaceAll(constraint.ConstraintType, " ", ""))
+	t.tableTags = append(t.tableTags, modifier+"={"+strings.Join(modifiers, " ")+"}")
+}
+
+func (t *structTable) column(columnName string) Column {
+	for _, column := range t.columns {
+		if column.ColumnName == columnName {
+			return column
+		}
+	}
+	return Column{ColumnName: columnName}
+}
+
+func removeTag(tags []string, tag string) []string {
+	result := tags[:0]
+	for _, s := range tags {
+		if s != tag {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func (t *structTable) addIndex(index Index) error {
+	// Indexes that back a primary key or unique constraint are already
+	// covered by the constraint.
+	for _, constraint := range t.constraints {
+		if constraint.ConstraintName == index.IndexName && (constraint.ConstraintType == "PRIMARY KEY" || constraint.ConstraintType == "UNIQUE") {
+			return nil
+		}
+	}
+	if index.IndexSchema != "" && index.IndexSchema != t.tableName[0] {
+		return t.addIndexConstraint(index)
+	}
+	// Expressions are hung off the first column they mention, which is
+	// also the name they contribute to the generated index name.
+	// Expressions that mention no column are hung off the table's first
+	// column, and the index keeps its name through a name modifier.
+	names := make([]string, len(index.Columns))
+	hasExprs, hasNames := false, true
+	for i, columnName := range index.Columns {
+		if i < len(index.Exprs) && index.Exprs[i] != "" {
+			hasExprs = true
+			columnName = t.exprColumn(index.Exprs[i])
+			if columnName == "" && len(t.columns) > 0 {
+				columnName, hasNames = t.columns[0].ColumnName, false
+			}
+		}
+		names[i] = columnName
+	}
+	if containsName(names, "") {
+		return fmt.Errorf("index %s: table has no columns to declare it on", index.IndexName)
+	}
+	id := "."
+	var indexModifiers []string
+	if !hasNames || index.IndexName != t.tableName[1]+"_"+strings.Join(names, "_")+"_idx" {
+		indexModifiers = append(indexModifiers, "name="+tagValue(index.IndexName))
+	}
+	if index.IndexType != "" && !strings.EqualFold(index.IndexType, "BTREE") {
+		indexModifiers = append(indexModifiers, "type="+strings.ToLower(index.IndexType))
+	}
+	if index.IsUnique {
+		indexModifiers = append(indexModifiers, "unique")
+	}
+	if index.IsPartial {
+		indexModifiers = append(indexModifiers, "where="+tagValue(index.Where))
+	}
+	if index.Online {
+		indexModifiers = append(indexModifiers, "online")
+	}
+	if len(index.Include) > 0 {
+		indexModifiers = append(indexModifiers, "include="+strings.Join(index.Include, ","))
+	}
+	hasKeyPartOptions := false
+	for i := range index.Columns {
+		if len(keyPartModifiers(index, i)) > 0 {
+			hasKeyPartOptions = true
+		}
+	}
+	switch {
+	case len(index.Columns) == 1 && !hasExprs:
+		modifiers := append(indexModifiers, keyPartModifiers(index, 0)...)
+		tag := "index"
+		if len(modifiers) > 0 {
+			tag = "index={" + id + " " + strings.Join(modifiers, " ") + "}"
+		}
+		t.columnTags[names[0]] = append(t.columnTags[names[0]], tag)
+	case !hasExprs && !hasKeyPartOptions:
+		modifiers := append([]string{id, "cols=" + strings.Join(index.Columns, ",")}, indexModifiers...)
+		t.tableTags = append(t.tableTags, "index={"+strings.Join(modifiers, " ")+"}")
+	default:
+		t.indexGroups++
+		id = strconv.Itoa(t.indexGroups)
+		for i, name := range names {
+			modifiers := []string{id, "order=" + strconv.Itoa(i+1)}
+			if i == 0 {
+				modifiers = append(modifiers, indexModifiers...)
+			}
+			if index.Exprs[i] != "" {
+				modifiers = append(modifiers, "expr="+tagValue(index.Exprs[i]))
+			}
+			modifiers = append(modifiers, keyPartModifiers(index, i)...)
+			t.columnTags[name] = append(t.columnTags[name], "index={"+strings.Join(modifiers, " ")+"}")
+		}
+	}
+	return nil
+}
+
+// keyPartModifiers returns the index tag modifiers of the ith key part's
+// options.
+func keyPartModifiers(index Index, i int) []string {
+	var modifiers []string
+	if i < len(index.Directions) && strings.EqualFold(index.Directions[i], "DESC") {
+		modifiers = append(modifiers, "desc")
+	}
+	if i < len(index.NullsOrders) && index.NullsOrders[i] != "" {
+		modifiers = append(modifiers, "nulls="+strings.ToLower(strings.TrimPrefix(index.NullsOrders[i], "NULLS ")))
+	}
+	if i < len(index.Opclasses) && index.Opclasses[i] != "" {
+		modifiers = append(modifiers, "opclass="+index.Opclasses[i])
+	}
+	if i < len(index.Collations) && index.Collations[i] != "" {
+		modifiers = append(modifiers, "collate="+tagValue(index.Collations[i]))
+	}
+	if i < len(index.PrefixLengths) && index.PrefixLengths[i] > 0 {
+		modifiers = append(modifiers, "prefix="+strconv.Itoa(index.PrefixLengths[i]))
+	}
+	return modifiers
+}
+
+// exprColumn returns the first of the table's columns mentioned in expr, or
+// an empty string if there is none.
+func (t *structTable) exprColumn(expr string) string {
+	tokens, err := tokenizeDDL("", expr)
+	if err != nil {
+		return ""
+	}
+	for _, token := range tokens {
+		if !isIdentifierToken(token.text) {
+			continue
+		}
+		name := unquoteIdentifier(token.text)
+		for _, column := range t.columns {
+			if strings.EqualFold(column.ColumnName, name) {
+				return column.ColumnName
+			}
+		}
+	}
+	return ""
+}
+
+// addIndexConstraint declares an index that tags cannot express in the
+// Constraints method. c.Index only takes fields, so an index with
+// expressions or a WHERE clause cannot be declared there either.
+func (t *structTable) addIndexConstraint(index Index) error {
+	reason := "tags cannot put an index in schema " + index.IndexSchema
+	if index.IsPartial {
+		return fmt.Errorf("index %s: %s and c.Index cannot declare its WHERE clause", index.IndexName, reason)
+	}
+	fields := make([]string, len(index.Columns))
+	for i, columnName := range index.Columns {
+		if i < len(index.Exprs) && index.Exprs[i] != "" {
+			return fmt.Errorf("index %s: %s and c.Index cannot declare its expressions", index.IndexName, reason)
+		}
+		fields[i] = t.field(columnName)
+	}
+	args := strconv.Quote(index.IndexSchema) + ", " + strconv.Quote(index.IndexName) + ", " + strconv.Quote(index.IndexType)
+	switch {
+	case len(index.Include) > 0:
+		include := make([]string, len(index.Include))
+		for i, columnName := range index.Include {
+			include[i] = t.field(columnName)
+		}
+		t.constraint = append(t.constraint, "c.IndexInclude("+args+", []Field{"+strings.Join(fields, ", ")+"}, "+strings.Join(include, ", ")+")")
+	case index.IsUnique:
+		t.constraint = append(t.constraint, "c.UniqueIndex("+args+", "+strings.Join(fields, ", ")+")")
+	default:
+		t.constraint = append(t.constraint, "c.Index("+args+", "+strings.Join(fields, ", ")+")")
+	}
+	return nil
+}
+
+// fieldWrapper returns the field wrapper of a column type.
+func fieldWrapper(columnType string) string {
+	typ := strings.ToUpper(strings.TrimSpace(columnType))
+	if i := strings.IndexAny(typ, "( "); i >= 0 {
+		if typ == "TINYINT(1)" {
+			return "booleanfield"
+		}
+		typ = typ[:i]
+	}
+	switch typ {
+	case "BOOL", "BOOLEAN":
+		return "booleanfield"
+	case "JSON", "JSONB":
+		return "jsonfield"
+	case "BLOB", "BYTEA", "BINARY", "VARBINARY", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB":
+		return "blobfield"
+	case "DATE", "TIME", "TIMETZ", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ", "INTERVAL":
+		return "timefield"
+	case "INT", "INTEGER", "YEAR", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "INT2", "INT4", "INT8",
+		"SERIAL", "SMALLSERIAL", "BIGSERIAL", "NUMERIC", "DECIMAL", "REAL", "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE":
+		return "numberfield"
+	}
+	return "stringfield"
+}
+
+// defaultFieldType returns the column type a field wrapper gets when its tag
+// has no type.
+func defaultFieldType(dialect string, wrapper string) string {
+	switch wrapper {
+	case "booleanfield":
+		return "BOOLEAN"
+	case "jsonfield":
+		if dialect == "postgres" {
+			return "JSONB"
+		}
+		return "JSON"
+	case "blobfield":
+		if dialect == "postgres" {
+			return "BYTEA"
+		}
+		return "BLOB"
+	case "timefield":
+		if dialect == "postgres" {
+			return "TIMESTAMPTZ"
+		}
+		return "DATETIME"
+	case "numberfield":
+		return "INT"
+	}
+	if dialect == "mysql" {
+		return "VARCHAR(255)"
+	}
+	return "TEXT"
+}
+
+// goName turns a table or column name into the upper case identifier used
+// for it in Go.
+func goName(name string) string {
+	b := make([]byte, 0, len(name)+1)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	if len(b) == 0 || (b[0] >= '0' && b[0] <= '9') {
+		b = append([]byte{'_'}, b...)
+	}
+	return string(b)
+}
@@ -0,0 +1,487 @@
+package metadata
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestGenerateStructs(t *testing.T) {
+	for _, tt := range []struct {
+		dialect, filename string
+		want              []string
+	}{
+		{"postgres", "pg-tables.sql", []string{
+			"c.Col(ACTOR.ACTOR_ID, c.Autoincrement(AutoincrementDefaultIdentity))",
+			"CATEGORY_ID numberfield `ddl:\"type=SERIAL primarykey\"`",
+			"RATING               stringfield `ddl:\"type=mpaa_rating default='G'::mpaa_rating\"`",
+		}},
+		{"mysql", "my-tables.sql", []string{
+			"c.Col(ACTOR.LAST_UPDATE, c.OnUpdateCurrentTimestamp)",
+			"c.Col(ACTOR.ACTOR_ID, c.Autoincrement(AutoincrementMySQL))",
+			"tableinfo   `ddl:\"name=film_text index={. cols=title,description type=fulltext}\"`",
+		}},
+		{"sqlite3", "sq-tables.sql", []string{
+			"type _ACTOR struct {\n" +
+				"\ttableinfo          `ddl:\"name=actor\"`\n" +
+				"\tACTOR_ID           numberfield `ddl:\"type=INTEGER primarykey\"`\n" +
+				"\tFIRST_NAME         stringfield `ddl:\"notnull\"`\n" +
+				"\tLAST_NAME          stringfield `ddl:\"notnull index\"`\n" +
+				"\tFULL_NAME          stringfield `ddl:\"generated={{first_name || ' ' || last_name} virtual}\"`\n" +
+				"\tFULL_NAME_REVERSED stringfield `ddl:\"generated={{last_name || ' ' || first_name} stored}\"`\n" +
+				"\tLAST_UPDATE        timefield   `ddl:\"notnull default=DATETIME('now')\"`\n" +
+				"}\n",
+			"tableinfo    `ddl:\"name=rental index={. cols=rental_date,inventory_id,customer_id unique}\"`",
+			"RENTAL_ID    numberfield `ddl:\"references={rental onupdate=cascade ondelete=setnull}\"`",
+			"tableinfo `ddl:\"name=dummy_table primarykey={dummy_table_id1_id2_pkey cols=id1,id2} unique={. cols=score,color}\"`",
+			"SCORE     numberfield `ddl:\"index={1 order=1 name=dummy_table_score_color_data_idx where={color = 'red'}}\"`",
+		}},
+	} {
+		t.Run(tt.dialect, func(t *testing.T) {
+			is := testutil.New(t)
+			ddlTables, err := LoadDDLFile(tt.dialect, tt.filename)
+			is.NoErr(err)
+			buf := &bytes.Buffer{}
+			is.NoErr(GenerateStructs(buf, tt.dialect, "tables", ddlTables))
+			is.NoErr(typeCheckStructs(buf.Bytes()))
+			for _, want := range tt.want {
+				is.True(strings.Contains(buf.String(), want))
+			}
+		})
+	}
+}
+
+// structsStubs declares what the package of the generated table structs is
+// expected to provide, alongside the C, Field and Table of constraints.go
+// and metadata.go that the Constraints methods are checked against.
+const structsStubs = `package tables
+
+import "bytes"
+
+type TypeChange struct{}
+type NotNullBackfill struct{}
+type Domain struct{}
+type Trigger struct{}
+
+type tableinfo [2]string
+
+func (t tableinfo) GetSchema() string { return t[0] }
+func (t tableinfo) GetName() string   { return t[1] }
+
+type field string
+type blobfield struct{ field }
+type booleanfield struct{ field }
+type jsonfield struct{ field }
+type numberfield struct{ field }
+type stringfield struct{ field }
+type timefield struct{ field }
+
+func (f field) GetName() string { return string(f) }
+func (f field) AppendSQLExclude(dialect string, buf *bytes.Buffer, args *[]interface{}, params map[string][]int, excludedTableQualifiers []string) error {
+	return nil
+}
+func (f blobfield) GetType() string    { return "blob" }
+func (f booleanfield) GetType() string { return "boolean" }
+func (f jsonfield) GetType() string    { return "json" }
+func (f numberfield) GetType() string  { return "number" }
+func (f stringfield) GetType() string  { return "string" }
+func (f timefield) GetType() string    { return "time" }
+`
+
+// typeCheckStructs type checks the output of GenerateStructs for a package
+// named tables.
+func typeCheckStructs(src []byte) error {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, filename := range []string{"constraints.go", "metadata.go"} {
+		file, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return err
+		}
+		file.Name.Name = "tables"
+		// Blank imports like the sqlite3 driver play no part in type
+		// checking and are slow to import from source.
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+				specs := decl.Specs[:0]
+				for _, spec := range decl.Specs {
+					if spec := spec.(*ast.ImportSpec); spec.Name == nil || spec.Name.Name != "_" {
+						specs = append(specs, spec)
+					}
+				}
+				decl.Specs = specs
+			}
+		}
+		files = append(files, file)
+	}
+	stubs, err := parser.ParseFile(fset, "stubs.go", structsStubs, 0)
+	if err != nil {
+		return err
+	}
+	file, err := parser.ParseFile(fset, "tables.go", src, 0)
+	if err != nil {
+		return err
+	}
+	files = append(files, stubs, file)
+	config := types.Config{Importer: importer.Default()}
+	_, err = config.Check("tables", fset, files, nil)
+	return err
+}
+
+func TestGenerateStructsFallbacks(t *testing.T) {
+	is := testutil.New(t)
+	tableName := [2]string{"", "t"}
+	tables := mockTables{
+		tables: [][2]string{tableName},
+		columns: map[[2]string]map[string]Column{tableName: {
+			"id":   {ColumnName: "id", ColumnType: "INT", IsPrimaryKey: true, Autoincrement: autoincrementAlwaysIdentity},
+			"name": {ColumnName: "name", ColumnType: "TEXT", ColumnDefault: sql.NullString{String: "'`'", Valid: true}},
+			"data": {ColumnName: "data", ColumnType: "JSONB", Comment: sql.NullString{String: "raw data", Valid: true}},
+		}},
+		constraints: map[[2]string]map[string]TableConstraint{tableName: {
+			"t_self_fkey": {ConstraintName: "t_self_fkey", ConstraintType: "FOREIGN KEY", Columns: []string{"id", "name"}, ReferencesTable: "u", ReferencesColumns: []string{"id", "name"}, OnDelete: sql.NullString{String: "CASCADE", Valid: true}},
+		}},
+		indices: map[[2]string]map[[2]string]Index{tableName: {
+			{"", "t_data_idx"}:     {IndexName: "t_data_idx", Columns: []string{""}, Exprs: []string{"lower(data)"}},
+			{"", "t_id_name_idx"}:  {IndexName: "t_id_name_idx", Columns: []string{"id", "name"}, Exprs: []string{"", ""}, Directions: []string{"", "DESC"}},
+			{"", "t_name_lookup"}:  {IndexName: "t_name_lookup", IndexType: "HASH", Columns: []string{"name"}, Exprs: []string{""}},
+			{"", "t_name_gin_idx"}: {IndexName: "t_name_gin_idx", IsUnique: true, Columns: []string{"name"}, Exprs: []string{""}},
+			{"s", "t_id_idx"}:      {IndexSchema: "s", IndexName: "t_id_idx", IndexType: "HASH", Columns: []string{"id"}, Exprs: []string{""}},
+			{"", "t_now_idx"}:      {IndexName: "t_now_idx", Columns: []string{""}, Exprs: []string{"now()"}},
+		}},
+	}
+	buf := &bytes.Buffer{}
+	is.NoErr(GenerateStructs(buf, "postgres", "tables", tables))
+	is.NoErr(typeCheckStructs(buf.Bytes()))
+	for _, want := range []string{
+		"tableinfo `ddl:\"name=t foreignkey={t_self_fkey cols=id,name references={u cols=id,name} ondelete=cascade}\"`",
+		"ID        numberfield `ddl:\"primarykey index={2 order=1} index={3 order=1 name=t_now_idx expr=now()}\"`",
+		"DATA      jsonfield   `ddl:\"comment={raw data} index={1 order=1 expr=lower(data)}\"`",
+		"NAME      stringfield `ddl:\"index={2 order=2 desc} index={. name=t_name_gin_idx unique} index={. name=t_name_lookup type=hash}\"`",
+		"c.Col(T.NAME, c.Default(\"'`'\"))",
+		"c.Index(\"s\", \"t_id_idx\", \"HASH\", T.ID)",
+		"c.Col(T.ID, c.Autoincrement(AutoincrementAlwaysIdentity))",
+	} {
+		is.True(strings.Contains(buf.String(), want))
+	}
+
+	tables.indices[tableName] = map[[2]string]Index{
+		{"s", "t_data_idx"}: {IndexSchema: "s", IndexName: "t_data_idx", Columns: []string{""}, Exprs: []string{"lower(data)"}},
+	}
+	is.True(GenerateStructs(&bytes.Buffer{}, "postgres", "tables", tables) != nil)
+	tables.indices[tableName] = map[[2]string]Index{
+		{"", "1"}: {IndexName: "1", Columns: []string{"name"}, Exprs: []string{""}, IsPartial: true, Where: "name <> ''"},
+	}
+	buf.Reset()
+	is.NoErr(GenerateStructs(buf, "postgres", "tables", tables))
+	is.True(strings.Contains(buf.String(), "NAME      stringfield `ddl:\"index={. name=1 where={name <> ''}}\"`"))
+
+	tables.indices[tableName] = nil
+	tables.columns[tableName]["na-me"] = Column{ColumnName: "na-me", ColumnType: "TEXT"}
+	tables.columns[tableName]["na_me"] = Column{ColumnName: "na_me", ColumnType: "TEXT"}
+	is.True(GenerateStructs(&bytes.Buffer{}, "postgres", "tables", tables) != nil)
+	delete(tables.columns[tableName], "na-me")
+	otherName := [2]string{"", "T"}
+	tables.tables = append(tables.tables, otherName)
+	tables.columns[otherName] = map[string]Column{"id": {ColumnName: "id", ColumnType: "INT"}}
+	is.True(GenerateStructs(&bytes.Buffer{}, "postgres", "tables", tables) != nil)
+	is.True(typeCheckStructs([]byte("package tables\n\nfunc f(c *C) { c.Index(\"\", \"t_idx\", \"\", nil, 1) }\n")) != nil)
+}
+
+func TestGenerateStructsRoundTrip(t *testing.T) {
+	for _, tt := range []struct{ dialect, filename string }{
+		{"postgres", "pg-tables.sql"},
+		{"mysql", "my-tables.sql"},
+		{"sqlite3", "sq-tables.sql"},
+	} {
+		t.Run(tt.dialect, func(t *testing.T) {
+			is := testutil.New(t)
+			ddlTables, err := LoadDDLFile(tt.dialect, tt.filename)
+			is.NoErr(err)
+			buf := &bytes.Buffer{}
+			is.NoErr(GenerateStructs(buf, tt.dialect, "tables", ddlTables))
+			structTables, err := structsTables(tt.dialect, buf.Bytes())
+			is.NoErr(err)
+			want, err := tableFacts(tt.dialect, ddlTables)
+			is.NoErr(err)
+			got, err := tableFacts(tt.dialect, structTables)
+			is.NoErr(err)
+			is.Equal(want, got)
+		})
+	}
+}
+
+// structsTables reads tables back from the ddl tags of generated table
+// structs. What the Constraints methods declare is not read.
+func structsTables(dialect string, src []byte) (mockTables, error) {
+	tables := mockTables{
+		columns:     make(map[[2]string]map[string]Column),
+		constraints: make(map[[2]string]map[string]TableConstraint),
+		indices:     make(map[[2]string]map[[2]string]Index),
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "tables.go", src, 0)
+	if err != nil {
+		return tables, err
+	}
+	for _, decl := range file.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.TYPE {
+			continue
+		}
+		typeSpec := decl.Specs[0].(*ast.TypeSpec)
+		var tableName [2]string
+		columns := make(map[string]Column)
+		constraints := make(map[string]TableConstraint)
+		var parts []indexPart
+		for _, field := range typeSpec.Type.(*ast.StructType).Fields.List {
+			var tag string
+			if field.Tag != nil {
+				tag, err = strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					return tables, err
+				}
+			}
+			modifiers, err := lexModifiers(reflect.StructTag(tag).Get("ddl"))
+			if err != nil {
+				return tables, err
+			}
+			if len(field.Names) == 0 {
+				for _, modifier := range modifiers {
+					switch modifier[0] {
+					case "name":
+						tableName[1] = modifier[1]
+					case "index":
+						part, err := indexPartFromModifier(dialect, tableName, "", modifier[1])
+						if err != nil {
+							return tables, err
+						}
+						parts = append(parts, part)
+					default:
+						constraint, err := tableConstraintFromModifier(dialect, tableName, modifier)
+						if err != nil {
+							return tables, err
+						}
+						constraints[constraint.ConstraintName] = constraint
+					}
+				}
+				continue
+			}
+			column := Column{
+				TableName:  tableName[1],
+				ColumnName: strings.ToLower(field.Names[0].Name),
+				ColumnType: defaultFieldType(dialect, field.Type.(*ast.Ident).Name),
+			}
+			for _, modifier := range modifiers {
+				switch modifier[0] {
+				case "type":
+					column.ColumnType = modifier[1]
+				case "primarykey":
+					column.IsPrimaryKey = true
+				case "notnull":
+					column.IsNotNull = true
+				case "unique":
+					column.IsUnique = true
+				case "default":
+					column.ColumnDefault = sql.NullString{String: modifier[1], Valid: true}
+				case "collate":
+					column.Collation = sql.NullString{String: modifier[1], Valid: true}
+				case "comment":
+					column.Comment = sql.NullString{String: modifier[1], Valid: true}
+				case "generated":
+					expr, kind, err := lexValue(modifier[1])
+					if err != nil {
+						return tables, err
+					}
+					column.GeneratedExpr = sql.NullString{String: expr, Valid: true}
+					column.GeneratedStored = len(kind) > 0 && kind[0][0] == "stored"
+				case "references":
+					refTable, refModifiers, err := lexValue(modifier[1])
+					if err != nil {
+						return tables, err
+					}
+					if i := strings.Index(refTable, "."); i >= 0 {
+						column.ReferencesSchema = sql.NullString{String: refTable[:i], Valid: true}
+						refTable = refTable[i+1:]
+					}
+					column.ReferencesTable = sql.NullString{String: refTable, Valid: true}
+					for _, refModifier := range refModifiers {
+						value := sql.NullString{String: strings.ToUpper(refModifier[1]), Valid: true}
+						switch refModifier[0] {
+						case "cols":
+							column.ReferencesColumn = sql.NullString{String: refModifier[1], Valid: true}
+						case "onupdate":
+							column.ReferencesOnUpdate = value
+						case "ondelete":
+							column.ReferencesOnDelete = value
+						}
+					}
+				case "index":
+					part, err := indexPartFromModifier(dialect, tableName, column.ColumnName, modifier[1])
+					if err != nil {
+						return tables, err
+					}
+					parts = append(parts, part)
+				default:
+					return tables, fmt.Errorf("%s.%s: unknown modifier %s", tableName[1], column.ColumnName, modifier[0])
+				}
+			}
+			columns[column.ColumnName] = column
+		}
+		indexes, err := indexesFromParts(dialect, parts)
+		if err != nil {
+			return tables, err
+		}
+		tables.indices[tableName] = make(map[[2]string]Index)
+		for _, index := range indexes {
+			tables.indices[tableName][[2]string{"", index.IndexName}] = index
+		}
+		tables.tables = append(tables.tables, tableName)
+		tables.columns[tableName] = columns
+		tables.constraints[tableName] = constraints
+	}
+	return tables, nil
+}
+
+// tableFacts describes each table by what ddl tags can declare about it, in
+// a form that does not depend on whether a key was declared on a column or on
+// the table. Autoincrement, CHECK constraints and what else only the
+// Constraints method can declare are left out.
+func tableFacts(dialect string, gotTables GotTables) (map[string][]string, error) {
+	tableNames, err := gotTables.GetTables()
+	if err != nil {
+		return nil, err
+	}
+	primaryKeys := make(map[string][]string)
+	for _, tableName := range tableNames {
+		columns, err := gotTables.GetColumns(tableName)
+		if err != nil {
+			return nil, err
+		}
+		constraints, err := gotTables.GetConstraints(tableName)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			if column.IsPrimaryKey {
+				primaryKeys[tableName[1]] = []string{column.ColumnName}
+			}
+		}
+		for _, constraint := range constraints {
+			if constraint.ConstraintType == "PRIMARY KEY" {
+				primaryKeys[tableName[1]] = constraint.Columns
+			}
+		}
+	}
+	refAction := func(action sql.NullString) string {
+		if s := strings.ToUpper(strings.TrimSpace(action.String)); s != "NO ACTION" {
+			return strings.ReplaceAll(s, " ", "")
+		}
+		return ""
+	}
+	facts := make(map[string][]string)
+	for _, tableName := range tableNames {
+		columns, err := gotTables.GetColumns(tableName)
+		if err != nil {
+			return nil, err
+		}
+		constraints, err := gotTables.GetConstraints(tableName)
+		if err != nil {
+			return nil, err
+		}
+		indices, err := gotTables.GetIndices(tableName)
+		if err != nil {
+			return nil, err
+		}
+		var list []string
+		keys := make(map[string]bool)
+		addKey := func(constraint TableConstraint) {
+			name := constraint.ConstraintName
+			if name == "" {
+				name = defaultConstraintName(dialect, tableName[1], constraint.ConstraintType, constraint.Columns)
+			}
+			key := constraint.ConstraintType + " " + name + " (" + strings.Join(constraint.Columns, ",") + ")"
+			if constraint.ConstraintType == "FOREIGN KEY" {
+				refColumns := constraint.ReferencesColumns
+				if len(refColumns) == 0 {
+					refColumns = primaryKeys[constraint.ReferencesTable]
+				}
+				key += " REFERENCES " + constraint.ReferencesTable + " (" + strings.Join(refColumns, ",") + ")" +
+					" ON UPDATE " + refAction(constraint.OnUpdate) + " ON DELETE " + refAction(constraint.OnDelete)
+			}
+			keys[key] = true
+		}
+		for _, column := range columns {
+			columnDefault := column.ColumnDefault.String
+			if strings.HasPrefix(strings.ToLower(columnDefault), "nextval(") {
+				columnDefault = ""
+			}
+			list = append(list, fmt.Sprintf("COLUMN %s %s NOT NULL=%t DEFAULT=%s GENERATED=%s STORED=%t COLLATE=%s COMMENT=%s",
+				column.ColumnName, normalizeType(column.ColumnType), column.IsNotNull || column.IsPrimaryKey,
+				normalizeExpr(columnDefault), normalizeExpr(column.GeneratedExpr.String), column.GeneratedStored,
+				column.Collation.String, column.Comment.String))
+			names := []string{column.ColumnName}
+			if column.IsPrimaryKey {
+				addKey(TableConstraint{ConstraintType: "PRIMARY KEY", Columns: names})
+			}
+			if column.IsUnique {
+				addKey(TableConstraint{ConstraintType: "UNIQUE", Columns: names})
+			}
+			if column.ReferencesTable.Valid {
+				var refColumns []string
+				if column.ReferencesColumn.String != "" {
+					refColumns = []string{column.ReferencesColumn.String}
+				}
+				addKey(TableConstraint{
+					ConstraintType:    "FOREIGN KEY",
+					Columns:           names,
+					ReferencesTable:   column.ReferencesTable.String,
+					ReferencesColumns: refColumns,
+					OnUpdate:          column.ReferencesOnUpdate,
+					OnDelete:          column.ReferencesOnDelete,
+				})
+			}
+		}
+		for _, constraint := range constraints {
+			if constraint.ConstraintType != "CHECK" {
+				addKey(constraint)
+			}
+		}
+		for key := range keys {
+			list = append(list, key)
+		}
+		for _, index := range indices {
+			if _, ok := constraints[index.IndexName]; ok {
+				continue
+			}
+			var keyParts []string
+			for i, columnName := range index.Columns {
+				keyPart := columnName
+				if i < len(index.Exprs) && index.Exprs[i] != "" {
+					keyPart = normalizeExpr(index.Exprs[i])
+				}
+				keyParts = append(keyParts, strings.TrimSpace(keyPart+" "+strings.Join(keyPartModifiers(index, i), " ")))
+			}
+			indexType := strings.ToUpper(index.IndexType)
+			if indexType == "BTREE" {
+				indexType = ""
+			}
+			list = append(list, fmt.Sprintf("INDEX %s UNIQUE=%t TYPE=%s (%s) WHERE=%s",
+				index.IndexName, index.IsUnique, indexType, strings.Join(keyParts, ", "), normalizeExpr(index.Where)))
+		}
+		sort.Strings(list)
+		facts[tableName[1]] = list
+	}
+	return facts, nil
+}
@@ -0,0 +1,243 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// collationClause returns the COLLATE clause for a collation. Postgres
+// collation names are case sensitive identifiers (e.g. "C" and "en-US-x-icu"),
+// so they are always quoted.
+func collationClause(dialect string, collation string) string {
+	if dialect == "postgres" && !strings.HasPrefix(collation, `"`) {
+		return `COLLATE "` + strings.ReplaceAll(collation, `"`, `""`) + `"`
+	}
+	return "COLLATE " + collation
+}
+
+// normalizeColumnCollation returns a collation name for comparison, with the name
+// of the dialect's default collation (default on Postgres, BINARY on SQLite)
+// treated as unspecified.
+func normalizeColumnCollation(dialect string, collation sql.NullString) string {
+	if !collation.Valid {
+		return ""
+	}
+	name := strings.Trim(collation.String, `"`+"`")
+	switch {
+	case dialect == "postgres" && name == "default":
+		return ""
+	case dialect == "sqlite3" && strings.EqualFold(name, "BINARY"):
+		return ""
+	case dialect == "postgres":
+		return name
+	case dialect == "mysql":
+		return normalizeCollation(name)
+	default:
+		return strings.ToLower(name)
+	}
+}
+
+// collationEqual reports whether two column collations are the same, where a
+// collation that is not specified is the same as the dialect's default.
+func collationEqual(dialect string, got, want sql.NullString) bool {
+	return normalizeColumnCollation(dialect, got) == normalizeColumnCollation(dialect, want)
+}
+
+// tableCollationEqual is like collationEqual, except that a collation that is
+// not specified is the same as the table's default collation, if it has one.
+func tableCollationEqual(dialect string, got, want sql.NullString, tableCollation string) bool {
+	gotName, wantName := normalizeColumnCollation(dialect, got), normalizeColumnCollation(dialect, want)
+	defaultName := normalizeColumnCollation(dialect, sql.NullString{String: tableCollation, Valid: tableCollation != ""})
+	if gotName == "" {
+		gotName = defaultName
+	}
+	if wantName == "" {
+		wantName = defaultName
+	}
+	return gotName == wantName
+}
+
+// getTableCollation returns the default collation of a MySQL table, which
+// its columns get unless they are declared with a collation of their own.
+// Other dialects have no table collation and return an empty string.
+func getTableCollation(db DB, dialect string, tableName [2]string) (string, error) {
+	if dialect != "mysql" {
+		return "", nil
+	}
+	opts, err := GetTableOptions(db, tableName)
+	if err != nil {
+		return "", err
+	}
+	return opts.Collation, nil
+}
+
+// GetCollations returns the collation of the columns of a table. On Postgres
+// these are the columns whose collation differs from the collation of their
+// type, and on SQLite, which does not report collations and has its CREATE
+// TABLE statement parsed instead, the ones whose collation is not BINARY. On
+// MySQL every column with a collation is reported, including the ones that
+// have the table's default collation (see getTableCollation).
+func GetCollations(db DB, dialect string, tableName [2]string) (map[string]string, error) {
+	var query string
+	switch dialect {
+	case "postgres":
+		query = "SELECT a.attname, c.collname FROM pg_attribute AS a" +
+			" JOIN pg_type AS t ON t.oid = a.atttypid" +
+			" JOIN pg_collation AS c ON c.oid = a.attcollation" +
+			" WHERE a.attrelid = to_regclass(quote_ident(COALESCE(NULLIF($1, ''), current_schema())) || '.' || quote_ident($2))" +
+			" AND a.attnum > 0 AND NOT a.attisdropped AND a.attcollation <> t.typcollation AND c.collname <> 'default'"
+	case "mysql":
+		query = "SELECT COLUMN_NAME, COLLATION_NAME FROM information_schema.COLUMNS" +
+			" WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND COLLATION_NAME IS NOT NULL"
+	case "sqlite3":
+		return getSQLiteCollations(db, tableName)
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	rows, err := db.Query(query, tableName[0], tableName[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", query, err)
+	}
+	defer rows.Close()
+	collations := make(map[string]string)
+	for rows.Next() {
+		var columnName, collation string
+		err = rows.Scan(&columnName, &collation)
+		if err != nil {
+			return nil, err
+		}
+		collations[columnName] = collation
+	}
+	return collations, rows.Err()
+}
+
+func getSQLiteCollations(db DB, tableName [2]string) (map[string]string, error) {
+	var query string
+	err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", tableName[1]).Scan(&query)
+	if err != nil {
+		return nil, err
+	}
+	_, columns, _, err := ParseSQLiteCreateTable(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", query, err)
+	}
+	collations := make(map[string]string)
+	for _, column := range columns {
+		if column.Collation.Valid && !strings.EqualFold(column.Collation.String, "BINARY") {
+			collations[column.ColumnName] = column.Collation.String
+		}
+	}
+	return collations, nil
+}
+
+// FillCollations sets the Collation of the columns of a table, introspected
+// from the database, to their collations as reported by GetCollations. A
+// column with its default collation (on MySQL, the table's default
+// collation) is left without one.
+func FillCollations(db DB, dialect string, tableName [2]string, columns map[string]Column) error {
+	collations, err := GetCollations(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	tableCollation, err := getTableCollation(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	for columnName, column := range columns {
+		collation, ok := collations[columnName]
+		if ok && tableCollation != "" && normalizeCollation(collation) == normalizeCollation(tableCollation) {
+			collation, ok = "", false
+		}
+		column.Collation = sql.NullString{String: collation, Valid: ok}
+		columns[columnName] = column
+	}
+	return nil
+}
+
+// AlterCollation returns the queries that change the collation of a column in
+// tableName to the one it has in wantTables, or back to the default if it has
+// none. Postgres and MySQL restate the column's type with the new collation,
+// while SQLite rebuilds the table (see AlterColumnType).
+func AlterCollation(db DB, dialect string, wantTables WantTables, tableName [2]string, columnName string) (querylist []string, err error) {
+	columns, err := wantTables.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	column, ok := columns[columnName]
+	if !ok {
+		return nil, fmt.Errorf("column %s not found in table %s", columnName, tableName[1])
+	}
+	if column.ColumnType == "" {
+		return nil, fmt.Errorf("column %s has no type to restate", columnName)
+	}
+	table := qualifiedName(dialect, tableName[0], tableName[1])
+	switch dialect {
+	case "postgres":
+		collation := "default"
+		if column.Collation.Valid {
+			collation = column.Collation.String
+		}
+		return []string{"ALTER TABLE " + table + " ALTER COLUMN " + quoteIdentifier(dialect, columnName) + " TYPE " + column.ColumnType + " " + collationClause(dialect, collation)}, nil
+	case "mysql":
+		return []string{"ALTER TABLE " + table + " MODIFY COLUMN " + modifyColumnDefinition(column)}, nil
+	case "sqlite3":
+		return sqliteRebuildTable(db, wantTables, tableName, nil)
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+// EnsureCollations changes the collation of every column of tableName whose
+// collation in the database has drifted from the one in wantTables. On
+// SQLite the table is rebuilt at most once, which must be done with foreign
+// keys turned off.
+func EnsureCollations(db DB, dialect string, wantTables WantTables, tableName [2]string) error {
+	wantColumns, err := wantTables.GetColumns(tableName)
+	if err != nil {
+		return err
+	}
+	gotColumns, err := getColumnNames(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	collations, err := GetCollations(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	tableCollation, err := getTableCollation(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	columnNames := make([]string, 0, len(wantColumns))
+	for columnName := range wantColumns {
+		columnNames = append(columnNames, columnName)
+	}
+	sort.Strings(columnNames)
+	var querylist []string
+	for _, columnName := range columnNames {
+		if !gotColumns[columnName] {
+			continue
+		}
+		collation, ok := collations[columnName]
+		if tableCollationEqual(dialect, sql.NullString{String: collation, Valid: ok}, wantColumns[columnName].Collation, tableCollation) {
+			continue
+		}
+		queries, err := AlterCollation(db, dialect, wantTables, tableName, columnName)
+		if err != nil {
+			return err
+		}
+		querylist = append(querylist, queries...)
+		if dialect == "sqlite3" {
+			break
+		}
+	}
+	for _, query := range querylist {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
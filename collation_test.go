@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestCollations(t *testing.T) {
+	is := testutil.New(t)
+	email := Column{TableName: "customer", ColumnName: "email", ColumnType: "TEXT", Collation: sql.NullString{String: "C", Valid: true}}
+	is.Equal(`email TEXT COLLATE "C"`, columnDefinition("postgres", email))
+	email.Collation.String = "utf8mb4_bin"
+	is.Equal("email TEXT COLLATE utf8mb4_bin", columnDefinition("mysql", email))
+	email.Collation.String = "NOCASE"
+	is.Equal("email TEXT COLLATE NOCASE", columnDefinition("sqlite3", email))
+
+	binary := email
+	binary.Collation.String = "BINARY"
+	unspecified := email
+	unspecified.Collation = sql.NullString{}
+	is.Equal(0, len(ColumnMismatches("sqlite3", binary, unspecified)))
+	is.Equal([]string{"collation"}, ColumnMismatches("sqlite3", unspecified, email))
+	is.Equal(0, len(ColumnMismatches("postgres", Column{Collation: sql.NullString{String: "default", Valid: true}}, Column{})))
+
+	// A MySQL column reports the table's default collation whether it was
+	// declared with it or without a collation.
+	tableDefault := sql.NullString{String: "utf8mb4_0900_ai_ci", Valid: true}
+	is.True(tableCollationEqual("mysql", tableDefault, sql.NullString{}, "utf8mb4_0900_ai_ci"))
+	is.True(tableCollationEqual("mysql", tableDefault, tableDefault, "utf8mb4_0900_ai_ci"))
+	is.True(tableCollationEqual("mysql", sql.NullString{String: "utf8_bin", Valid: true}, sql.NullString{String: "utf8mb3_bin", Valid: true}, "utf8mb4_0900_ai_ci"))
+	is.True(!tableCollationEqual("mysql", tableDefault, sql.NullString{String: "utf8mb4_bin", Valid: true}, "utf8mb4_0900_ai_ci"))
+	is.True(!tableCollationEqual("mysql", sql.NullString{String: "utf8mb4_bin", Valid: true}, sql.NullString{}, "utf8mb4_0900_ai_ci"))
+	is.True(tableCollationEqual("sqlite3", sql.NullString{}, sql.NullString{String: "BINARY", Valid: true}, ""))
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE "customer" (
+    customer_id INTEGER PRIMARY KEY
+    ,[email] TEXT COLLATE "nocase" -- emails are case insensitive
+    ,name TEXT /* COLLATE RTRIM */ COLLATE BINARY
+    ,code TEXT CHECK (code <> 'COLLATE x') COLLATE RTRIM
+    ,UNIQUE (email)
+)`)
+	is.NoErr(err)
+	collations, err := GetCollations(db, "sqlite3", [2]string{"", "customer"})
+	is.NoErr(err)
+	is.Equal(map[string]string{"email": "nocase", "code": "RTRIM"}, collations)
+}
+
+func TestEnsureCollations(t *testing.T) {
+	is := testutil.New(t)
+	tableName := [2]string{"", "customer"}
+	wantTables := mockTables{
+		tables: [][2]string{tableName},
+		columns: map[[2]string]map[string]Column{tableName: {
+			"customer_id": {TableName: "customer", ColumnName: "customer_id", ColumnType: "INTEGER", IsPrimaryKey: true},
+			"email":       {TableName: "customer", ColumnName: "email", ColumnType: "TEXT", Collation: sql.NullString{String: "NOCASE", Valid: true}},
+			"name":        {TableName: "customer", ColumnName: "name", ColumnType: "VARCHAR(255)", Comment: sql.NullString{String: "full name", Valid: true}},
+		}},
+		createTable: map[[2]string][]string{
+			tableName: {"CREATE TABLE customer (customer_id INTEGER PRIMARY KEY, email TEXT COLLATE NOCASE, name TEXT)"},
+		},
+	}
+	querylist, err := AlterCollation(nil, "postgres", wantTables, tableName, "email")
+	is.NoErr(err)
+	is.Equal([]string{`ALTER TABLE customer ALTER COLUMN email TYPE TEXT COLLATE "NOCASE"`}, querylist)
+	querylist, err = AlterCollation(nil, "postgres", wantTables, tableName, "name")
+	is.NoErr(err)
+	is.Equal([]string{`ALTER TABLE customer ALTER COLUMN name TYPE VARCHAR(255) COLLATE "default"`}, querylist)
+	querylist, err = AlterCollation(nil, "mysql", wantTables, tableName, "name")
+	is.NoErr(err)
+	is.Equal([]string{"ALTER TABLE customer MODIFY COLUMN name VARCHAR(255) COMMENT 'full name'"}, querylist)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE customer (customer_id INTEGER PRIMARY KEY, email TEXT COLLATE RTRIM, name TEXT)")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO customer (email, name) VALUES ('A@example.com', 'MARY')")
+	is.NoErr(err)
+	columns, err := wantTables.GetColumns(tableName)
+	is.NoErr(err)
+	gotColumns := map[string]Column{"email": columns["email"], "name": columns["name"]}
+	is.NoErr(FillCollations(db, "sqlite3", tableName, gotColumns))
+	is.Equal(sql.NullString{String: "RTRIM", Valid: true}, gotColumns["email"].Collation)
+	is.Equal([]string{"collation"}, ColumnMismatches("sqlite3", gotColumns["email"], columns["email"]))
+
+	is.NoErr(EnsureCollations(db, "sqlite3", wantTables, tableName))
+	collations, err := GetCollations(db, "sqlite3", tableName)
+	is.NoErr(err)
+	is.Equal(map[string]string{"email": "NOCASE"}, collations)
+	var count int
+	is.NoErr(db.QueryRow("SELECT COUNT(*) FROM customer WHERE email = 'a@EXAMPLE.com'").Scan(&count))
+	is.Equal(1, count)
+	is.NoErr(EnsureCollations(db, "sqlite3", wantTables, tableName))
+}
@@ -0,0 +1,165 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// commentFromModifier parses the value of a comment tag modifier, as in
+// comment={Two-letter ISO 3166 code}.
+func commentFromModifier(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: true}
+}
+
+// tableCommentQuery returns the query that sets (or, if comment is not valid,
+// removes) the comment on a table. SQLite has no comments, so the query is
+// empty.
+func tableCommentQuery(dialect string, tableName [2]string, comment sql.NullString) string {
+	name := qualifiedName(dialect, tableName[0], tableName[1])
+	switch dialect {
+	case "postgres":
+		return "COMMENT ON TABLE " + name + " IS " + commentLiteral(comment)
+	case "mysql":
+		return "ALTER TABLE " + name + " COMMENT = " + quoteLiteral(comment.String)
+	default:
+		return ""
+	}
+}
+
+// columnCommentQuery returns the query that sets the comment on a column.
+// MySQL can only change a column's comment by redefining the whole column, so
+// column has to be complete.
+func columnCommentQuery(dialect string, column Column) string {
+	name := qualifiedName(dialect, column.TableSchema, column.TableName)
+	switch dialect {
+	case "postgres":
+		return "COMMENT ON COLUMN " + name + "." + quoteIdentifier(dialect, column.ColumnName) + " IS " + commentLiteral(column.Comment)
+	case "mysql":
+		if !column.Comment.Valid {
+			column.Comment = sql.NullString{Valid: true}
+		}
+		return "ALTER TABLE " + name + " MODIFY COLUMN " + modifyColumnDefinition(column)
+	default:
+		return ""
+	}
+}
+
+func commentLiteral(comment sql.NullString) string {
+	if !comment.Valid {
+		return "NULL"
+	}
+	return quoteLiteral(comment.String)
+}
+
+// GetComments returns the comment on a table and on each of its columns that
+// has one. MySQL reports an empty comment as no comment.
+func GetComments(db DB, dialect string, tableName [2]string) (tableComment sql.NullString, columnComments map[string]string, err error) {
+	var tableQuery, columnQuery string
+	switch dialect {
+	case "postgres":
+		tableQuery = "SELECT obj_description(to_regclass(quote_ident(COALESCE(NULLIF($1, ''), current_schema())) || '.' || quote_ident($2)), 'pg_class')"
+		columnQuery = "SELECT a.attname, d.description FROM pg_attribute AS a" +
+			" JOIN pg_description AS d ON d.objoid = a.attrelid AND d.classoid = 'pg_class'::regclass AND d.objsubid = a.attnum" +
+			" WHERE a.attrelid = to_regclass(quote_ident(COALESCE(NULLIF($1, ''), current_schema())) || '.' || quote_ident($2)) AND a.attnum > 0 AND NOT a.attisdropped"
+	case "mysql":
+		tableQuery = "SELECT NULLIF(TABLE_COMMENT, '') FROM information_schema.TABLES WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?"
+		columnQuery = "SELECT COLUMN_NAME, COLUMN_COMMENT FROM information_schema.COLUMNS" +
+			" WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ? AND COLUMN_COMMENT <> ''"
+	case "sqlite3":
+		return tableComment, nil, nil
+	default:
+		return tableComment, nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	err = db.QueryRow(tableQuery, tableName[0], tableName[1]).Scan(&tableComment)
+	if err != nil && err != sql.ErrNoRows {
+		return tableComment, nil, fmt.Errorf("%s: %w", tableQuery, err)
+	}
+	rows, err := db.Query(columnQuery, tableName[0], tableName[1])
+	if err != nil {
+		return tableComment, nil, fmt.Errorf("%s: %w", columnQuery, err)
+	}
+	defer rows.Close()
+	columnComments = make(map[string]string)
+	for rows.Next() {
+		var columnName, comment string
+		err = rows.Scan(&columnName, &comment)
+		if err != nil {
+			return tableComment, nil, err
+		}
+		columnComments[columnName] = comment
+	}
+	return tableComment, columnComments, rows.Err()
+}
+
+// EnsureComments sets the comments on a table and its columns that differ
+// from the ones in the database. Columns without a valid Comment are left
+// alone. It does nothing on SQLite.
+func EnsureComments(db DB, dialect string, tableName [2]string, tableComment sql.NullString, columns []Column) error {
+	if dialect == "sqlite3" {
+		return nil
+	}
+	gotTableComment, gotColumnComments, err := GetComments(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	var querylist []string
+	if tableComment.Valid && tableComment.String != gotTableComment.String {
+		querylist = append(querylist, tableCommentQuery(dialect, tableName, tableComment))
+	}
+	for _, column := range columns {
+		if column.Comment.Valid && column.Comment.String != gotColumnComments[column.ColumnName] {
+			querylist = append(querylist, columnCommentQuery(dialect, column))
+		}
+	}
+	for _, query := range querylist {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
+
+// StructFieldComments returns the doc comments of the fields of a struct
+// type declared in Go source, keyed by field name, so that they can be used
+// as column comments.
+func StructFieldComments(src []byte, structName string) (map[string]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var comments map[string]string
+	ast.Inspect(file, func(node ast.Node) bool {
+		typeSpec, ok := node.(*ast.TypeSpec)
+		if !ok || typeSpec.Name.Name != structName {
+			return comments == nil
+		}
+		structType, ok := typeSpec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		comments = make(map[string]string)
+		for _, field := range structType.Fields.List {
+			doc := field.Doc
+			if doc == nil {
+				doc = field.Comment
+			}
+			if doc == nil {
+				continue
+			}
+			text := strings.Join(strings.Fields(doc.Text()), " ")
+			for _, name := range field.Names {
+				comments[name.Name] = text
+			}
+		}
+		return false
+	})
+	if comments == nil {
+		return nil, fmt.Errorf("struct %s not found", structName)
+	}
+	return comments, nil
+}
@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestCommentQueries(t *testing.T) {
+	is := testutil.New(t)
+	comment := commentFromModifier("Two-letter ISO 3166 code, e.g. 'SG'")
+	is.Equal("COMMENT ON TABLE public.country IS 'Two-letter ISO 3166 code, e.g. ''SG'''", tableCommentQuery("postgres", [2]string{"public", "country"}, comment))
+	is.Equal("COMMENT ON TABLE country IS NULL", tableCommentQuery("postgres", [2]string{"", "country"}, sql.NullString{}))
+	is.Equal("", tableCommentQuery("sqlite3", [2]string{"", "country"}, comment))
+
+	column := Column{TableName: "country", ColumnName: "code", ColumnType: "CHAR(2)", IsNotNull: true, Comment: sql.NullString{String: "ISO code", Valid: true}}
+	is.Equal("COMMENT ON COLUMN country.code IS 'ISO code'", columnCommentQuery("postgres", column))
+	is.Equal("ALTER TABLE country MODIFY COLUMN code CHAR(2) NOT NULL COMMENT 'ISO code'", columnCommentQuery("mysql", column))
+	id := Column{TableName: "country", ColumnName: "country_id", ColumnType: "INT", IsNotNull: true, IsPrimaryKey: true, Autoincrement: autoincrementAutoIncrement}
+	is.Equal("ALTER TABLE country MODIFY COLUMN country_id INT NOT NULL AUTO_INCREMENT COMMENT ''", columnCommentQuery("mysql", id))
+
+	got := column
+	got.Comment = sql.NullString{}
+	is.Equal([]string{"comment"}, ColumnMismatches("postgres", got, column))
+	is.Equal(0, len(ColumnMismatches("sqlite3", got, column)))
+}
+
+func TestStructFieldComments(t *testing.T) {
+	is := testutil.New(t)
+	src := []byte(`package tables
+
+type COUNTRY struct {
+	tableinfo
+	// COUNTRY_ID is the surrogate key.
+	COUNTRY_ID numberfield
+	CODE       stringfield // Two-letter ISO 3166
+	// NAME is the English name.
+	NAME stringfield
+}
+`)
+	comments, err := StructFieldComments(src, "COUNTRY")
+	is.NoErr(err)
+	is.Equal(map[string]string{
+		"COUNTRY_ID": "COUNTRY_ID is the surrogate key.",
+		"CODE":       "Two-letter ISO 3166",
+		"NAME":       "NAME is the English name.",
+	}, comments)
+	_, err = StructFieldComments(src, "CITY")
+	is.True(err != nil)
+}
@@ -19,22 +19,52 @@ func (c *C) Name(name string) ColumnConstraint { return func() {} }
 
 func (c *C) Type(typ string) ColumnConstraint { return func() {} }
 
+func (c *C) AlterType(change TypeChange) ColumnConstraint { return func() {} }
+
 func (c *C) Generated(expr string, stored bool) ColumnConstraint { return func() {} }
 
 func (c *C) Default(expr string) ColumnConstraint { return func() {} }
 
+func (c *C) Backfill(backfill NotNullBackfill) ColumnConstraint { return func() {} }
+
+func (c *C) Enum(enumSchema, enumName string, values ...string) ColumnConstraint {
+	return func() {}
+}
+
+func (c *C) Domain(domain Domain) ColumnConstraint { return func() {} }
+
+func (c *C) FullText(config string, idxType string, fields ...Field) ColumnConstraint {
+	return func() {}
+}
+
 func (c *C) Collate(collation string) ColumnConstraint { return func() {} }
 
+func (c *C) Comment(comment string) ColumnConstraint { return func() {} }
+
+func (c *C) TableComment(comment string) {}
+
 func (c *C) CheckString(name string, expr string) {}
 
 func (c *C) Check(name string, p Predicate) {}
 
 func (c *C) OnUpdateCurrentTimestamp() {}
 
+func (c *C) Trigger(trigger Trigger) {}
+
+func (c *C) PrimaryKeyColumns(name string, fields ...Field) {}
+
+func (c *C) Unique(name string, fields ...Field) {}
+
+func (c *C) ForeignKey(name string, fields []Field, table Table, refFields []Field, onActions ...ReferenceOn) {}
+
 func (c *C) Index(idxSchema, idxName, idxType string, fields ...Field) {}
 
 func (c *C) UniqueIndex(idxSchema, idxName, idxType string, fields ...Field) {}
 
+// IndexInclude declares a covering index whose include fields are stored in
+// the index without being part of its key (Postgres only).
+func (c *C) IndexInclude(idxSchema, idxName, idxType string, fields []Field, include ...Field) {}
+
 type AutoincrementType string
 
 const (
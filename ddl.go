@@ -0,0 +1,421 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DB is the subset of *sql.DB and *sql.Tx needed to inspect and alter a
+// database.
+type DB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func quoteIdentifier(dialect string, name string) string {
+	needsQuoting := name == ""
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		needsQuoting = true
+		break
+	}
+	if !needsQuoting {
+		return name
+	}
+	if dialect == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func qualifiedName(dialect string, schema, name string) string {
+	if schema == "" || dialect == "sqlite3" {
+		return quoteIdentifier(dialect, name)
+	}
+	return quoteIdentifier(dialect, schema) + "." + quoteIdentifier(dialect, name)
+}
+
+func columnDefinition(dialect string, column Column) string {
+	buf := &strings.Builder{}
+	buf.WriteString(quoteIdentifier(dialect, column.ColumnName))
+	if column.ColumnType != "" {
+		buf.WriteString(" " + column.ColumnType)
+	}
+	if column.Collation.Valid {
+		buf.WriteString(" " + collationClause(dialect, column.Collation.String))
+	}
+	if column.GeneratedExpr.Valid {
+		buf.WriteString(" GENERATED ALWAYS AS (" + column.GeneratedExpr.String + ")")
+		if column.GeneratedStored {
+			buf.WriteString(" STORED")
+		} else {
+			buf.WriteString(" VIRTUAL")
+		}
+	}
+	if column.ColumnDefault.Valid {
+		if dialect == "sqlite3" {
+			buf.WriteString(" DEFAULT (" + column.ColumnDefault.String + ")")
+		} else {
+			buf.WriteString(" DEFAULT " + column.ColumnDefault.String)
+		}
+	}
+	if column.IsNotNull {
+		buf.WriteString(" NOT NULL")
+	}
+	if column.IsPrimaryKey {
+		buf.WriteString(" PRIMARY KEY")
+	}
+	if column.IsUnique {
+		buf.WriteString(" UNIQUE")
+	}
+	if dialect == "mysql" && column.OnUpdateCurrentTimestamp.Valid && column.OnUpdateCurrentTimestamp.Bool {
+		buf.WriteString(" ON UPDATE CURRENT_TIMESTAMP")
+	}
+	if dialect == "mysql" && column.Comment.Valid {
+		buf.WriteString(" COMMENT " + quoteLiteral(column.Comment.String))
+	}
+	return buf.String()
+}
+
+func quoteIdentifiers(dialect string, names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdentifier(dialect, name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func constraintDefinition(dialect string, constraint TableConstraint) (string, error) {
+	buf := &strings.Builder{}
+	// MySQL always names a primary key PRIMARY, which cannot be given as a
+	// constraint name.
+	if constraint.ConstraintName != "" && !(dialect == "mysql" && constraint.ConstraintType == "PRIMARY KEY") {
+		buf.WriteString("CONSTRAINT " + quoteIdentifier(dialect, constraint.ConstraintName) + " ")
+	}
+	switch constraint.ConstraintType {
+	case "PRIMARY KEY", "UNIQUE":
+		if len(constraint.Columns) == 0 {
+			return "", fmt.Errorf("%s constraint %s has no columns", constraint.ConstraintType, constraint.ConstraintName)
+		}
+		buf.WriteString(constraint.ConstraintType + " (" + quoteIdentifiers(dialect, constraint.Columns) + ")")
+	case "CHECK":
+		if !constraint.CheckExpr.Valid {
+			return "", fmt.Errorf("CHECK constraint %s has no expression", constraint.ConstraintName)
+		}
+		buf.WriteString("CHECK (" + constraint.CheckExpr.String + ")")
+	case "FOREIGN KEY":
+		if len(constraint.Columns) == 0 || constraint.ReferencesTable == "" {
+			return "", fmt.Errorf("FOREIGN KEY constraint %s must have columns and a referenced table", constraint.ConstraintName)
+		}
+		if dialect == "mysql" && len(constraint.ReferencesColumns) == 0 {
+			return "", fmt.Errorf("mysql: FOREIGN KEY constraint %s must name the columns it references", constraint.ConstraintName)
+		}
+		buf.WriteString("FOREIGN KEY (" + quoteIdentifiers(dialect, constraint.Columns) + ")")
+		buf.WriteString(" REFERENCES " + qualifiedName(dialect, constraint.ReferencesSchema, constraint.ReferencesTable))
+		if len(constraint.ReferencesColumns) > 0 {
+			buf.WriteString(" (" + quoteIdentifiers(dialect, constraint.ReferencesColumns) + ")")
+		}
+		if constraint.OnUpdate.Valid {
+			buf.WriteString(" ON UPDATE " + constraint.OnUpdate.String)
+		}
+		if constraint.OnDelete.Valid {
+			buf.WriteString(" ON DELETE " + constraint.OnDelete.String)
+		}
+	default:
+		return "", fmt.Errorf("unknown constraint type %q", constraint.ConstraintType)
+	}
+	return buf.String(), nil
+}
+
+// AddConstraintQuery returns the ALTER TABLE query that adds a constraint to
+// an existing table. SQLite cannot add constraints after a table has been
+// created, so they have to go into its CREATE TABLE.
+func AddConstraintQuery(dialect string, constraint TableConstraint) (string, error) {
+	if dialect == "sqlite3" {
+		return "", fmt.Errorf("sqlite3: cannot add constraint %s to an existing table", constraint.ConstraintName)
+	}
+	definition, err := constraintDefinition(dialect, constraint)
+	if err != nil {
+		return "", err
+	}
+	return "ALTER TABLE " + qualifiedName(dialect, constraint.TableSchema, constraint.TableName) + " ADD " + definition, nil
+}
+
+// columnForeignKey returns the single-column FOREIGN KEY constraint declared
+// by a column's References* fields.
+func columnForeignKey(column Column) TableConstraint {
+	constraint := TableConstraint{
+		TableSchema:      column.TableSchema,
+		TableName:        column.TableName,
+		ConstraintName:   column.TableName + "_" + column.ColumnName + "_fkey",
+		ConstraintType:   "FOREIGN KEY",
+		Columns:          []string{column.ColumnName},
+		ReferencesSchema: column.ReferencesSchema.String,
+		ReferencesTable:  column.ReferencesTable.String,
+		OnUpdate:         column.ReferencesOnUpdate,
+		OnDelete:         column.ReferencesOnDelete,
+	}
+	if column.ReferencesColumn.Valid {
+		constraint.ReferencesColumns = []string{column.ReferencesColumn.String}
+	}
+	return constraint
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// normalizeExpr loosely normalizes an SQL expression for comparison, so that
+// the expression a database reports back (e.g. Postgres' fully parenthesized
+// "((VALUE >= 1901) AND (VALUE <= 2155))" or "'english'::regconfig") compares
+// equal to the expression that was declared. Whitespace, Postgres :: casts
+// and parentheses that operator precedence makes redundant are dropped, and
+// keywords and identifiers outside of string literals are lowercased.
+func normalizeExpr(expr string) string {
+	tokens := removeCasts(exprTokens(expr))
+	for {
+		i, j := redundantParens(tokens)
+		if i < 0 {
+			break
+		}
+		tokens = append(tokens[:i], append(tokens[i+1:j], tokens[j+1:]...)...)
+	}
+	buf := &strings.Builder{}
+	for i, token := range tokens {
+		if i > 0 && isIdentifierByte(tokens[i-1][len(tokens[i-1])-1]) && isIdentifierByte(token[0]) {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(token)
+	}
+	return buf.String()
+}
+
+// exprTokens splits an expression into string literals, lowercased words,
+// operators and punctuation. Quoted identifiers that do not need quoting are
+// unquoted.
+func exprTokens(expr string) []string {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(expr); i++ {
+				if expr[i] == c {
+					if i+1 < len(expr) && expr[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+			if i > len(expr) {
+				i = len(expr)
+			}
+			token := expr[start:i]
+			if c != '\'' && len(token) > 2 {
+				if name := token[1 : len(token)-1]; quoteIdentifier("", name) == name && strings.ToLower(name) == name {
+					token = name
+				}
+			}
+			tokens = append(tokens, token)
+			continue
+		case isIdentifierByte(c) || c == '.' || c == '$':
+			for i < len(expr) && (isIdentifierByte(expr[i]) || expr[i] == '.' || expr[i] == '$') {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(expr[start:i]))
+			continue
+		case strings.IndexByte(exprOperatorBytes, c) >= 0:
+			for i < len(expr) && strings.IndexByte(exprOperatorBytes, expr[i]) >= 0 {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, expr[start:i])
+	}
+	return tokens
+}
+
+const exprOperatorBytes = "<>=!|+-*/%~&^@#?:"
+
+// castTypeWords are the words that continue a multi-word type name, as in
+// ::character varying or ::timestamp with time zone.
+var castTypeWords = map[string]bool{"varying": true, "precision": true, "with": true, "without": true, "time": true, "zone": true}
+
+// removeCasts drops every ::type, including its type modifiers and array
+// brackets.
+func removeCasts(tokens []string) []string {
+	result := tokens[:0:0]
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] != "::" {
+			result = append(result, tokens[i])
+			continue
+		}
+		i++
+		for i+1 < len(tokens) && castTypeWords[tokens[i+1]] {
+			i++
+		}
+		if i+1 < len(tokens) && tokens[i+1] == "(" {
+			i = matchingParen(tokens, i+1)
+		}
+		for i+2 < len(tokens) && tokens[i+1] == "[" && tokens[i+2] == "]" {
+			i += 2
+		}
+	}
+	return result
+}
+
+// exprPrecedence is how tightly each binary operator binds. Operators not
+// listed (like -> or @>) bind tighter than comparisons but looser than
+// arithmetic.
+var exprPrecedence = map[string]int{
+	"or": 1, "and": 2, "not": 3,
+	"=": 4, "<>": 4, "!=": 4, "<": 4, ">": 4, "<=": 4, ">=": 4, "is": 4, "like": 4, "ilike": 4, "in": 4, "between": 4,
+	"+": 6, "-": 6, "*": 7, "/": 7, "%": 7,
+}
+
+// exprBoundaries are the words that separate expressions the way a comma
+// does.
+var exprBoundaries = map[string]bool{"case": true, "when": true, "then": true, "else": true, "end": true, "select": true, "where": true, "on": true, "from": true}
+
+func operatorPrecedence(token string) int {
+	if p, ok := exprPrecedence[token]; ok {
+		return p
+	}
+	if strings.IndexByte(exprOperatorBytes, token[0]) >= 0 {
+		return 5
+	}
+	return 0
+}
+
+func matchingParen(tokens []string, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// redundantParens returns the positions of the first pair of parentheses
+// that can be dropped without changing what the expression means, or -1.
+// Parentheses are redundant when they wrap a single term, or when every
+// operator inside them binds tighter than the operators on either side.
+// Function call and IN (...) parentheses are never redundant.
+func redundantParens(tokens []string) (int, int) {
+	for i, token := range tokens {
+		if token != "(" {
+			continue
+		}
+		before := 0
+		if i > 0 {
+			prev := tokens[i-1]
+			switch {
+			case prev == "(" || prev == "," || prev == "[" || exprBoundaries[prev]:
+			case prev == "in" || operatorPrecedence(prev) == 0:
+				continue
+			default:
+				before = operatorPrecedence(prev)
+			}
+		}
+		j := matchingParen(tokens, i)
+		after := 0
+		if j+1 < len(tokens) {
+			after = operatorPrecedence(tokens[j+1])
+		}
+		inner, innerOp := 100, ""
+		depth := 0
+		for k := i + 1; k < j; k++ {
+			switch tokens[k] {
+			case "(":
+				depth++
+				continue
+			case ")":
+				depth--
+				continue
+			}
+			p := operatorPrecedence(tokens[k])
+			// An operator with no operand before it is unary.
+			unary := k == i+1 || (tokens[k-1] != ")" && operatorPrecedence(tokens[k-1]) > 0) || tokens[k-1] == "("
+			if depth > 0 || p == 0 || (unary && tokens[k] != "not") {
+				continue
+			}
+			if p < inner {
+				inner, innerOp = p, tokens[k]
+			}
+		}
+		associative := i > 0 && (innerOp == "and" || innerOp == "or") && tokens[i-1] == innerOp
+		if (inner > before || (inner == before && associative)) && inner >= after {
+			return i, j
+		}
+	}
+	return -1, -1
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+var typeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"int2":        "smallint",
+	"bool":        "boolean",
+	"float4":      "real",
+	"float8":      "double precision",
+	"decimal":     "numeric",
+	"varchar":     "character varying",
+	"char":        "character",
+	"timestamptz": "timestamp with time zone",
+	"timestamp":   "timestamp without time zone",
+}
+
+// normalizeType normalizes a Postgres column type for comparison, mapping
+// aliases like INT and TIMESTAMPTZ to the names Postgres reports them as.
+// Quoted values, like those of a MySQL ENUM, are kept as is.
+func normalizeType(typ string) string {
+	typ = strings.TrimSpace(typ)
+	name, rest := typ, ""
+	if i := strings.IndexAny(typ, "(["); i >= 0 {
+		name, rest = strings.TrimSpace(typ[:i]), typ[i:]
+	}
+	name = strings.ToLower(name)
+	if alias, ok := typeAliases[name]; ok {
+		name = alias
+	}
+	buf := &strings.Builder{}
+	buf.WriteString(name)
+	inString := false
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+		case inString:
+		case c == ' ':
+			continue
+		case c >= 'A' && c <= 'Z':
+			c += 'a' - 'A'
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
@@ -0,0 +1,42 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestNormalizeExpr(t *testing.T) {
+	equal := func(t *testing.T, a, b string) {
+		is := testutil.New(t)
+		is.Equal(normalizeExpr(a), normalizeExpr(b))
+	}
+	notEqual := func(t *testing.T, a, b string) {
+		is := testutil.New(t)
+		is.True(normalizeExpr(a) != normalizeExpr(b))
+	}
+	equal(t, "((VALUE >= 1901) AND (VALUE <= 2155))", "VALUE >= 1901 AND VALUE <= 2155")
+	equal(t, "'x'::character varying", "'x'")
+	equal(t, "'2006-02-15 04:34:33'::timestamp without time zone", "'2006-02-15 04:34:33'")
+	equal(t, "(ARRAY['a'::text, 'b'::text])::character varying(20)[]", "ARRAY['a', 'b']")
+	equal(t, "to_tsvector('english'::regconfig, (title)::text)", "TO_TSVECTOR('english', title)")
+	equal(t, "((a OR b) OR c)", "a OR b OR c")
+	equal(t, "(a * b) + c", "a * b + c")
+	equal(t, "NOT (a = b)", "NOT a = b")
+	equal(t, `"score" > 0`, "score > 0")
+	notEqual(t, "(a OR b) AND c", "a OR (b AND c)")
+	notEqual(t, "(a OR b) AND c", "a OR b AND c")
+	notEqual(t, "a - (b - c)", "a - b - c")
+	notEqual(t, "NOT (a AND b)", "NOT a AND b")
+	notEqual(t, "x IN (1, 2)", "x IN 1, 2")
+	notEqual(t, "'a b'", "'ab'")
+	notEqual(t, `"Score" > 0`, "score > 0")
+}
+
+func TestNormalizeType(t *testing.T) {
+	is := testutil.New(t)
+	is.Equal(normalizeType("VARCHAR(10)"), normalizeType("character varying (10)"))
+	is.Equal(normalizeType("ENUM('G', 'PG-13')"), normalizeType("enum('G','PG-13')"))
+	is.True(normalizeType("enum('G','PG')") != normalizeType("enum('g','pg')"))
+	is.True(normalizeType("enum('NC 17')") != normalizeType("enum('NC17')"))
+}
@@ -0,0 +1,329 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ddlTable is a table parsed from its CREATE TABLE statement.
+type ddlTable struct {
+	tableName         [2]string
+	columns           []Column
+	constraints       []TableConstraint
+	indexes           []Index
+	query             string
+	closingParen      int               // position of the ) that closes the definitions in query
+	columnDefinitions map[string]string // column definitions as written
+}
+
+// DDLTables is a WantTables read from hand-written DDL: the CREATE TABLE,
+// CREATE INDEX and ALTER TABLE ... ADD CONSTRAINT statements of a dialect.
+// Every other statement (DROP TABLE, CREATE TRIGGER, CREATE FUNCTION...) is
+// ignored, so a whole schema file can be loaded as is.
+type DDLTables struct {
+	dialect     string
+	tableNames  [][2]string
+	tables      map[[2]string]*ddlTable
+	alters      map[[2]string][]ddlConstraint
+	indexes     map[[2]string]map[[2]string]Index
+	createIndex map[[2]string]string
+}
+
+// ddlConstraint is a constraint added by ALTER TABLE, along with its
+// definition as written.
+type ddlConstraint struct {
+	constraint TableConstraint
+	definition string
+}
+
+// LoadDDLFile reads a DDL file into DDLTables.
+func LoadDDLFile(dialect string, filename string) (*DDLTables, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ddlTables, err := ParseDDL(dialect, string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return ddlTables, nil
+}
+
+// ParseDDL parses the CREATE TABLE, CREATE INDEX and ALTER TABLE ... ADD
+// CONSTRAINT statements in src into DDLTables.
+func ParseDDL(dialect string, src string) (*DDLTables, error) {
+	statements, err := splitStatements(dialect, src)
+	if err != nil {
+		return nil, err
+	}
+	d := &DDLTables{
+		dialect:     dialect,
+		tables:      make(map[[2]string]*ddlTable),
+		alters:      make(map[[2]string][]ddlConstraint),
+		indexes:     make(map[[2]string]map[[2]string]Index),
+		createIndex: make(map[[2]string]string),
+	}
+	for _, tokens := range statements {
+		p := &ddlParser{dialect: dialect, query: src, tokens: tokens}
+		query := p.text(0, len(tokens))
+		switch {
+		case p.accept("CREATE", "TABLE"), p.accept("CREATE", "TEMP", "TABLE"), p.accept("CREATE", "TEMPORARY", "TABLE"):
+			table, err := parseCreateTable(dialect, src, tokens)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", firstLine(query), err)
+			}
+			if d.dialect == "sqlite3" {
+				table.tableName[0] = ""
+			}
+			table.query = query
+			table.closingParen -= tokens[0].start
+			if _, ok := d.tables[table.tableName]; ok {
+				return nil, fmt.Errorf("table %s is created twice", table.tableName[1])
+			}
+			d.tableNames = append(d.tableNames, table.tableName)
+			d.tables[table.tableName] = &table
+			for _, index := range table.indexes {
+				d.addIndex(index, "")
+			}
+		case p.accept("CREATE", "INDEX"), p.accept("CREATE", "UNIQUE", "INDEX"), p.accept("CREATE", "FULLTEXT", "INDEX"), p.accept("CREATE", "SPATIAL", "INDEX"):
+			index, err := parseCreateIndex(dialect, src, tokens)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", firstLine(query), err)
+			}
+			if d.dialect == "sqlite3" {
+				index.TableSchema, index.IndexSchema = "", ""
+			}
+			index.TableSchema = d.resolveTableName([2]string{index.TableSchema, index.TableName})[0]
+			d.addIndex(index, query)
+		case p.accept("ALTER", "TABLE"):
+			p.accept("ONLY")
+			var tableName [2]string
+			tableName[0], tableName[1], err = p.qualifiedName()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", firstLine(query), err)
+			}
+			tableName = d.resolveTableName(tableName)
+			if !p.accept("ADD") {
+				continue
+			}
+			start := p.pos
+			if !p.isTableConstraint() {
+				continue
+			}
+			constraint, err := p.tableConstraint(tableName)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", firstLine(query), err)
+			}
+			if constraint.ConstraintName == "" {
+				constraints, _ := d.GetConstraints(tableName)
+				existing := make([]TableConstraint, 0, len(constraints))
+				for _, c := range constraints {
+					existing = append(existing, c)
+				}
+				constraint.ConstraintName = mysqlConstraintName(tableName[1], constraint.ConstraintType, existing)
+			}
+			d.alters[tableName] = append(d.alters[tableName], ddlConstraint{
+				constraint: constraint,
+				definition: p.text(start, len(tokens)),
+			})
+		}
+	}
+	for tableName, alters := range d.alters {
+		if _, ok := d.tables[tableName]; ok {
+			continue
+		}
+		// The table may be created after it is altered.
+		resolved := d.resolveTableName(tableName)
+		if _, ok := d.tables[resolved]; !ok {
+			return nil, fmt.Errorf("ALTER TABLE on table %s that is never created", tableName[1])
+		}
+		for i := range alters {
+			alters[i].constraint.TableSchema = resolved[0]
+		}
+		d.alters[resolved] = append(d.alters[resolved], alters...)
+		delete(d.alters, tableName)
+	}
+	return d, nil
+}
+
+// resolveTableName returns the name of the created table that tableName
+// refers to. Postgres creates unqualified tables in public unless the
+// search_path says otherwise, so film and public.film are taken to be the
+// same table.
+func (d *DDLTables) resolveTableName(tableName [2]string) [2]string {
+	if _, ok := d.tables[tableName]; ok || d.dialect != "postgres" {
+		return tableName
+	}
+	other := tableName
+	switch tableName[0] {
+	case "":
+		other[0] = "public"
+	case "public":
+		other[0] = ""
+	default:
+		return tableName
+	}
+	if _, ok := d.tables[other]; ok {
+		return other
+	}
+	return tableName
+}
+
+func (d *DDLTables) addIndex(index Index, query string) {
+	tableName := [2]string{index.TableSchema, index.TableName}
+	if d.indexes[tableName] == nil {
+		d.indexes[tableName] = make(map[[2]string]Index)
+	}
+	indexName := [2]string{index.IndexSchema, index.IndexName}
+	d.indexes[tableName][indexName] = index
+	if query == "" {
+		var err error
+		query, err = createIndexQuery(d.dialect, index)
+		if err != nil {
+			return
+		}
+	}
+	d.createIndex[indexName] = query
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func (d *DDLTables) table(tableName [2]string) (*ddlTable, error) {
+	table, ok := d.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("no such table %v", tableName)
+	}
+	return table, nil
+}
+
+// GetTables returns the tables in the order they are created in the DDL.
+func (d *DDLTables) GetTables() (tableNames [][2]string, err error) {
+	return d.tableNames, nil
+}
+
+func (d *DDLTables) GetColumns(tableName [2]string) (columns map[string]Column, err error) {
+	table, err := d.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	columns = make(map[string]Column)
+	for _, column := range table.columns {
+		columns[column.ColumnName] = column
+	}
+	return columns, nil
+}
+
+// GetColumnNames returns a table's column names in the order they are
+// declared.
+func (d *DDLTables) GetColumnNames(tableName [2]string) (columnNames []string, err error) {
+	table, err := d.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range table.columns {
+		columnNames = append(columnNames, column.ColumnName)
+	}
+	return columnNames, nil
+}
+
+// GetConstraints returns the constraints declared in a table's CREATE TABLE
+// and added to it by ALTER TABLE.
+func (d *DDLTables) GetConstraints(tableName [2]string) (constraints map[string]TableConstraint, err error) {
+	table, err := d.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	constraints = make(map[string]TableConstraint)
+	for _, constraint := range table.constraints {
+		constraints[constraint.ConstraintName] = constraint
+	}
+	for _, alter := range d.alters[tableName] {
+		constraints[alter.constraint.ConstraintName] = alter.constraint
+	}
+	return constraints, nil
+}
+
+func (d *DDLTables) GetIndices(tableName [2]string) (indices map[[2]string]Index, err error) {
+	return d.indexes[tableName], nil
+}
+
+// CreateTable returns the table's CREATE TABLE statement with the
+// constraints added to it by ALTER TABLE folded in, except for the foreign
+// keys that SortTables defers because they form a cycle.
+func (d *DDLTables) CreateTable(tableName [2]string) (querylist []string, argslist [][]interface{}, err error) {
+	var deferred []string
+	if d.dialect != "sqlite3" && len(d.alters[tableName]) > 0 {
+		_, deferredConstraints, err := SortTables(d)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, constraint := range deferredConstraints {
+			if [2]string{constraint.TableSchema, constraint.TableName} == tableName {
+				deferred = append(deferred, constraint.ConstraintName)
+			}
+		}
+	}
+	return d.CreateTableWithoutForeignKeys(tableName, deferred)
+}
+
+// CreateTableWithoutForeignKeys returns the table's CREATE TABLE statement
+// with the constraints added to it by ALTER TABLE folded in, except for the
+// named foreign keys. Only foreign keys added by ALTER TABLE can be left out.
+func (d *DDLTables) CreateTableWithoutForeignKeys(tableName [2]string, constraintNames []string) (querylist []string, argslist [][]interface{}, err error) {
+	table, err := d.table(tableName)
+	if err != nil {
+		return nil, nil, err
+	}
+	alters := d.alters[tableName]
+	altered := make(map[string]bool)
+	for _, alter := range alters {
+		altered[alter.constraint.ConstraintName] = true
+	}
+	omitted := make(map[string]bool)
+	for _, constraintName := range constraintNames {
+		if !altered[constraintName] {
+			return nil, nil, fmt.Errorf("table %s: foreign key %s is declared in CREATE TABLE and cannot be left out of it; add it with ALTER TABLE instead", tableName[1], constraintName)
+		}
+		omitted[constraintName] = true
+	}
+	if len(alters) == 0 {
+		return []string{table.query}, nil, nil
+	}
+	buf := &strings.Builder{}
+	buf.WriteString(strings.TrimRight(table.query[:table.closingParen], " \t\r\n"))
+	for _, alter := range alters {
+		if !omitted[alter.constraint.ConstraintName] {
+			buf.WriteString("\n    ," + alter.definition)
+		}
+	}
+	buf.WriteString("\n" + table.query[table.closingParen:])
+	return []string{buf.String()}, nil, nil
+}
+
+// CreateColumn returns the ALTER TABLE statement that adds a column, using
+// its definition in CREATE TABLE.
+func (d *DDLTables) CreateColumn(tableName [2]string, columnName string) (query string, args []interface{}, err error) {
+	table, err := d.table(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+	definition, ok := table.columnDefinitions[columnName]
+	if !ok {
+		return "", nil, fmt.Errorf("no such column %s", columnName)
+	}
+	return "ALTER TABLE " + qualifiedName(d.dialect, tableName[0], tableName[1]) + " ADD COLUMN " + definition, nil, nil
+}
+
+func (d *DDLTables) CreateIndex(indexName [2]string) (query string, args []interface{}, err error) {
+	query, ok := d.createIndex[indexName]
+	if !ok {
+		return "", nil, fmt.Errorf("no such index %v", indexName)
+	}
+	return query, nil, nil
+}
@@ -0,0 +1,234 @@
+package metadata
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestLoadDDLFile(t *testing.T) {
+	for _, tt := range []struct {
+		dialect, filename string
+		tables            int
+	}{
+		{"postgres", "pg-tables.sql", 16},
+		{"mysql", "my-tables.sql", 17},
+		{"sqlite3", "sq-tables.sql", 16},
+	} {
+		t.Run(tt.dialect, func(t *testing.T) {
+			is := testutil.New(t)
+			ddlTables, err := LoadDDLFile(tt.dialect, tt.filename)
+			is.NoErr(err)
+			tableNames, err := ddlTables.GetTables()
+			is.NoErr(err)
+			is.Equal(tt.tables, len(tableNames))
+			_, deferred, err := SortTables(ddlTables)
+			is.NoErr(err)
+			is.Equal(1, len(deferred))
+
+			constraints, err := ddlTables.GetConstraints([2]string{"", "payment"})
+			is.NoErr(err)
+			is.Equal(sql.NullString{String: "SET NULL", Valid: true}, constraints["payment_rental_id_fkey"].OnDelete)
+			constraints, err = ddlTables.GetConstraints([2]string{"", "dummy_table"})
+			is.NoErr(err)
+			is.Equal([]string{"id1", "id2"}, constraints["dummy_table_id1_id2_pkey"].Columns)
+			is.Equal("score > id1", constraints["dummy_table_score_id1_greater_than_check"].CheckExpr.String)
+
+			indexes, err := ddlTables.GetIndices([2]string{"", "dummy_table"})
+			is.NoErr(err)
+			index := indexes[[2]string{"", "dummy_table_score_color_data_idx"}]
+			is.Equal([]string{"score", "", "color"}, index.Columns)
+			is.True(index.Exprs[1] != "")
+			query, _, err := ddlTables.CreateIndex([2]string{"", "dummy_table_score_color_data_idx"})
+			is.NoErr(err)
+			is.True(strings.HasPrefix(query, "CREATE INDEX dummy_table_score_color_data_idx ON dummy_table"))
+
+			columns, err := ddlTables.GetColumns([2]string{"", "actor"})
+			is.NoErr(err)
+			is.True(columns["actor_id"].IsPrimaryKey)
+			is.True(columns["actor_id"].Autoincrement != autoincrementNone)
+			is.True(columns["full_name_reversed"].GeneratedStored)
+		})
+	}
+}
+
+func TestParseDDL(t *testing.T) {
+	is := testutil.New(t)
+	pg, err := LoadDDLFile("postgres", "pg-tables.sql")
+	is.NoErr(err)
+	columns, err := pg.GetColumns([2]string{"", "film"})
+	is.NoErr(err)
+	is.Equal("'G'::mpaa_rating", columns["rating"].ColumnDefault.String)
+	is.Equal("TEXT[]", columns["special_features"].ColumnType)
+	is.Equal(autoincrementIdentity, columns["film_id"].Autoincrement)
+	columns, err = pg.GetColumns([2]string{"", "dummy_table"})
+	is.NoErr(err)
+	is.Equal(sql.NullString{String: "C", Valid: true}, columns["color"].Collation)
+	is.Equal("'red'", columns["color"].ColumnDefault.String)
+	indexes, err := pg.GetIndices([2]string{"", "dummy_table"})
+	is.NoErr(err)
+	index := indexes[[2]string{"", "dummy_table_score_color_data_idx"}]
+	is.Equal("(data->>'age')::INT", index.Exprs[1])
+	is.Equal("color = 'red'", index.Where)
+	querylist, _, err := pg.CreateTable([2]string{"", "store"})
+	is.NoErr(err)
+	is.True(strings.Contains(querylist[0], "store_address_id_fkey"))
+	is.True(!strings.Contains(querylist[0], "store_manager_staff_id_fkey"))
+
+	my, err := LoadDDLFile("mysql", "my-tables.sql")
+	is.NoErr(err)
+	columns, err = my.GetColumns([2]string{"", "film"})
+	is.NoErr(err)
+	is.Equal("ENUM('G','PG','PG-13','R','NC-17')", columns["rating"].ColumnType)
+	is.Equal(autoincrementAutoIncrement, columns["film_id"].Autoincrement)
+	columns, err = my.GetColumns([2]string{"", "actor"})
+	is.NoErr(err)
+	is.True(columns["last_update"].OnUpdateCurrentTimestamp.Bool)
+	is.True(columns["last_update"].IsNotNull)
+	is.Equal("CURRENT_TIMESTAMP", columns["last_update"].ColumnDefault.String)
+	indexes, err = my.GetIndices([2]string{"", "film_text"})
+	is.NoErr(err)
+	is.Equal("FULLTEXT", indexes[[2]string{"", "film_text_title_description_idx"}].IndexType)
+
+	_, err = ParseDDL("postgres", "CREATE TABLE t (a INT);\nALTER TABLE u ADD CONSTRAINT u_a_fkey FOREIGN KEY (a) REFERENCES t (a);")
+	is.True(err != nil)
+}
+
+func TestParseDDLColumnConstraints(t *testing.T) {
+	is := testutil.New(t)
+	pg, err := ParseDDL("postgres", `
+CREATE TABLE parent (
+    id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY
+    ,code INT GENERATED BY DEFAULT AS IDENTITY
+);
+CREATE TABLE child (
+    id INT CONSTRAINT child_pk PRIMARY KEY
+    ,parent_id INT CONSTRAINT child_parent_fk REFERENCES parent (id) ON DELETE CASCADE
+    ,code TEXT DEFAULT NULL CONSTRAINT child_code_uq UNIQUE
+    ,note TEXT DEFAULT NULL::text NOT NULL
+);`)
+	is.NoErr(err)
+	columns, err := pg.GetColumns([2]string{"", "parent"})
+	is.NoErr(err)
+	is.Equal(autoincrementAlwaysIdentity, columns["id"].Autoincrement)
+	is.Equal(autoincrementIdentity, columns["code"].Autoincrement)
+	is.True(columns["id"].IsPrimaryKey)
+	columns, err = pg.GetColumns([2]string{"", "child"})
+	is.NoErr(err)
+	is.Equal("NULL", columns["code"].ColumnDefault.String)
+	is.Equal("NULL::text", columns["note"].ColumnDefault.String)
+	is.True(columns["note"].IsNotNull)
+	is.True(!columns["id"].IsPrimaryKey)
+	constraints, err := pg.GetConstraints([2]string{"", "child"})
+	is.NoErr(err)
+	is.Equal("PRIMARY KEY", constraints["child_pk"].ConstraintType)
+	is.Equal([]string{"code"}, constraints["child_code_uq"].Columns)
+	is.Equal("parent", constraints["child_parent_fk"].ReferencesTable)
+	is.Equal([]string{"id"}, constraints["child_parent_fk"].ReferencesColumns)
+	is.Equal(sql.NullString{String: "CASCADE", Valid: true}, constraints["child_parent_fk"].OnDelete)
+
+	my, err := ParseDDL("mysql", `
+CREATE TABLE parent (id INT PRIMARY KEY);
+CREATE TABLE child (
+    id INT PRIMARY KEY
+    ,parent_id INT CHECK (parent_id > 0)
+    ,qty INT
+    ,CHECK (qty > 0)
+    ,FOREIGN KEY (parent_id) REFERENCES parent (id)
+);
+ALTER TABLE child ADD CHECK (qty < 100);`)
+	is.NoErr(err)
+	constraints, err = my.GetConstraints([2]string{"", "child"})
+	is.NoErr(err)
+	is.Equal("parent_id > 0", constraints["child_chk_1"].CheckExpr.String)
+	is.Equal("qty > 0", constraints["child_chk_2"].CheckExpr.String)
+	is.Equal("qty < 100", constraints["child_chk_3"].CheckExpr.String)
+	is.Equal([]string{"parent_id"}, constraints["child_ibfk_1"].Columns)
+}
+
+func TestParseDDLDeferredForeignKeys(t *testing.T) {
+	is := testutil.New(t)
+	pg, err := ParseDDL("postgres", `
+CREATE TABLE app.staff (
+    staff_id INT PRIMARY KEY
+    ,store_id INT NOT NULL
+    ,CONSTRAINT staff_store_id_fkey FOREIGN KEY (store_id) REFERENCES app.store (store_id)
+);
+CREATE TABLE app.store (
+    store_id INT PRIMARY KEY
+    ,manager_staff_id INT NOT NULL
+);
+ALTER TABLE app.store ADD CONSTRAINT store_manager_staff_id_fkey FOREIGN KEY (manager_staff_id) REFERENCES app.staff (staff_id);
+`)
+	is.NoErr(err)
+	staff, store := [2]string{"app", "staff"}, [2]string{"app", "store"}
+	tableNames, deferred, err := SortTables(pg)
+	is.NoErr(err)
+	is.Equal([][2]string{store, staff}, tableNames)
+	is.Equal(1, len(deferred))
+	is.Equal(store, [2]string{deferred[0].TableSchema, deferred[0].TableName})
+	querylist, _, err := pg.CreateTable(store)
+	is.NoErr(err)
+	is.True(!strings.Contains(querylist[0], "store_manager_staff_id_fkey"))
+	querylist, _, err = pg.CreateTableWithoutForeignKeys(store, nil)
+	is.NoErr(err)
+	is.True(strings.Contains(querylist[0], "store_manager_staff_id_fkey"))
+	_, _, err = pg.CreateTableWithoutForeignKeys(staff, []string{"staff_store_id_fkey"})
+	is.True(err != nil)
+	query, err := AddConstraintQuery("postgres", deferred[0])
+	is.NoErr(err)
+	is.Equal("ALTER TABLE app.store ADD CONSTRAINT store_manager_staff_id_fkey FOREIGN KEY (manager_staff_id) REFERENCES app.staff (staff_id)", query)
+}
+
+func TestParseDDLDefaultSchema(t *testing.T) {
+	is := testutil.New(t)
+	pg, err := ParseDDL("postgres", `
+CREATE TABLE public.film (film_id INT PRIMARY KEY, title TEXT NOT NULL);
+CREATE TABLE actor (actor_id INT PRIMARY KEY);
+ALTER TABLE film ADD CONSTRAINT film_title_check CHECK (title <> '');
+ALTER TABLE public.actor ADD CONSTRAINT actor_actor_id_check CHECK (actor_id > 0);
+CREATE INDEX film_title_idx ON film (title);
+`)
+	is.NoErr(err)
+	film, actor := [2]string{"public", "film"}, [2]string{"", "actor"}
+	constraints, err := pg.GetConstraints(film)
+	is.NoErr(err)
+	is.Equal("public", constraints["film_title_check"].TableSchema)
+	constraints, err = pg.GetConstraints(actor)
+	is.NoErr(err)
+	is.Equal("", constraints["actor_actor_id_check"].TableSchema)
+	indices, err := pg.GetIndices(film)
+	is.NoErr(err)
+	is.Equal(1, len(indices))
+	querylist, _, err := pg.CreateTable(film)
+	is.NoErr(err)
+	is.True(strings.Contains(querylist[0], "film_title_check"))
+
+	_, err = ParseDDL("postgres", `
+CREATE TABLE app.film (film_id INT PRIMARY KEY);
+ALTER TABLE film ADD CONSTRAINT film_film_id_check CHECK (film_id > 0);
+`)
+	is.True(err != nil)
+}
+
+func TestEnsureDDLTables(t *testing.T) {
+	is := testutil.New(t)
+	b, err := os.ReadFile("sq-tables.sql")
+	is.NoErr(err)
+	// The JSON functions are not compiled into the test build.
+	src := strings.Replace(string(b), "CREATE INDEX dummy_table_score_color_data_idx", "-- ", 1)
+	ddlTables, err := ParseDDL("sqlite3", src)
+	is.NoErr(err)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	is.NoErr(EnsureTables(db, "sqlite3", ddlTables))
+	is.NoErr(EnsureTables(db, "sqlite3", ddlTables))
+	indexes, err := GetIndexes(db, "sqlite3", [2]string{"", "rental"})
+	is.NoErr(err)
+	is.Equal(4, len(indexes))
+}
@@ -0,0 +1,946 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ddlToken is a token of a DDL statement and its position in it.
+type ddlToken struct {
+	text       string
+	start, end int
+}
+
+// tokenizeDDL splits SQL into tokens: words, numbers, string literals
+// ('...', x'...', and E'...' and $tag$...$tag$ on Postgres), quoted
+// identifiers (kept quoted) and punctuation. Whitespace and comments are
+// dropped. [...] quotes an identifier only on SQLite, and MySQL also has #
+// comments and backslash escapes in strings.
+func tokenizeDDL(dialect string, query string) ([]ddlToken, error) {
+	var tokens []ddlToken
+	add := func(start, end int) {
+		tokens = append(tokens, ddlToken{text: query[start:end], start: start, end: end})
+	}
+	// quoted returns the end of the quoted text starting at i. Quotes are
+	// escaped by doubling them, except within [].
+	quoted := func(i int, closing byte, backslash bool) (int, error) {
+		for j := i + 1; j < len(query); j++ {
+			if backslash && query[j] == '\\' {
+				j++
+				continue
+			}
+			if query[j] != closing {
+				continue
+			}
+			if closing != ']' && j+1 < len(query) && query[j+1] == closing {
+				j++
+				continue
+			}
+			return j + 1, nil
+		}
+		return 0, fmt.Errorf("unterminated %c", query[i])
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#' && dialect == "mysql":
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += 2 + end + 2
+		case c == '\'' || c == '"' || c == '`' || (c == '[' && dialect == "sqlite3"):
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end, err := quoted(i, closing, dialect == "mysql" && c != '`')
+			if err != nil {
+				return nil, err
+			}
+			add(i, end)
+			i = end
+		case c == '$' && dialect == "postgres" && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %s", tag)
+			}
+			add(i, i+len(tag)+end+len(tag))
+			i += len(tag) + end + len(tag)
+		case isIdentifierByte(c) || c == '$' || c >= 0x80:
+			j := i
+			for j < len(query) && (isIdentifierByte(query[j]) || query[j] == '$' || query[j] >= 0x80 ||
+				(query[j] == '.' && c >= '0' && c <= '9')) {
+				j++
+			}
+			// x'...' blobs and Postgres E'...' strings
+			if j == i+1 && j < len(query) && query[j] == '\'' &&
+				(c == 'x' || c == 'X' || (dialect == "postgres" && (c == 'e' || c == 'E'))) {
+				end, err := quoted(j, '\'', c == 'e' || c == 'E')
+				if err != nil {
+					return nil, err
+				}
+				j = end
+			}
+			add(i, j)
+			i = j
+		default:
+			// Operators of more than one character are kept together.
+			n := 1
+			for _, operator := range []string{"->>", "||", "<=", ">=", "<>", "!=", "==", "<<", ">>", "->", "::"} {
+				if strings.HasPrefix(query[i:], operator) {
+					n = len(operator)
+					break
+				}
+			}
+			add(i, i+n)
+			i += n
+		}
+	}
+	return tokens, nil
+}
+
+// dollarTag returns the $tag$ that s starts with, if any.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isIdentifierByte(s[i]) || (i == 1 && s[i] >= '0' && s[i] <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+// splitStatements splits SQL into statements at each semicolon. Statements
+// with a body of their own (like triggers) are split apart too, which does
+// not matter for the statements the DDL parser cares about.
+func splitStatements(dialect string, src string) ([][]ddlToken, error) {
+	tokens, err := tokenizeDDL(dialect, src)
+	if err != nil {
+		return nil, err
+	}
+	var statements [][]ddlToken
+	var statement []ddlToken
+	for _, token := range tokens {
+		if token.text == ";" {
+			if len(statement) > 0 {
+				statements = append(statements, statement)
+			}
+			statement = nil
+			continue
+		}
+		statement = append(statement, token)
+	}
+	if len(statement) > 0 {
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// ddlParser walks the tokens of a DDL statement.
+type ddlParser struct {
+	dialect string
+	query   string
+	tokens  []ddlToken
+	pos     int
+}
+
+func (p *ddlParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *ddlParser) peek(keyword string) bool {
+	return p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+// accept consumes the keywords if they are next, in order.
+func (p *ddlParser) accept(keywords ...string) bool {
+	for i, keyword := range keywords {
+		if p.pos+i >= len(p.tokens) || !strings.EqualFold(p.tokens[p.pos+i].text, keyword) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *ddlParser) expect(keywords ...string) error {
+	if !p.accept(keywords...) {
+		return fmt.Errorf("expected %s near %q", strings.Join(keywords, " "), p.near())
+	}
+	return nil
+}
+
+func (p *ddlParser) near() string {
+	if p.done() {
+		return "end of statement"
+	}
+	near := p.query[p.tokens[p.pos].start:p.tokens[len(p.tokens)-1].end]
+	if len(near) > 40 {
+		near = near[:40] + "..."
+	}
+	return near
+}
+
+func (p *ddlParser) next() (string, error) {
+	if p.done() {
+		return "", fmt.Errorf("unexpected end of statement")
+	}
+	p.pos++
+	return p.tokens[p.pos-1].text, nil
+}
+
+func (p *ddlParser) name() (string, error) {
+	token, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	return unquoteIdentifier(token), nil
+}
+
+// qualifiedName consumes a name that may be qualified by a schema.
+func (p *ddlParser) qualifiedName() (schema, name string, err error) {
+	name, err = p.name()
+	if err != nil {
+		return "", "", err
+	}
+	if p.accept(".") {
+		schema = name
+		name, err = p.name()
+	}
+	return schema, name, err
+}
+
+// text returns the statement text spanning tokens[start:end].
+func (p *ddlParser) text(start, end int) string {
+	if start >= end {
+		return ""
+	}
+	return p.query[p.tokens[start].start:p.tokens[end-1].end]
+}
+
+// parenthesized consumes a parenthesized group and returns the text within it,
+// as written.
+func (p *ddlParser) parenthesized() (string, error) {
+	if !p.peek("(") {
+		return "", fmt.Errorf("expected ( near %q", p.near())
+	}
+	start := p.pos
+	depth := 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch p.tokens[p.pos].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if depth == 0 {
+			p.pos++
+			return p.text(start+1, p.pos-1), nil
+		}
+	}
+	return "", fmt.Errorf("unclosed parenthesis")
+}
+
+// list consumes a parenthesized, comma separated list and returns the tokens
+// of each of its items.
+func (p *ddlParser) list() ([][]ddlToken, error) {
+	if !p.accept("(") {
+		return nil, fmt.Errorf("expected ( near %q", p.near())
+	}
+	var items [][]ddlToken
+	var item []ddlToken
+	depth := 0
+	for ; p.pos < len(p.tokens); p.pos++ {
+		token := p.tokens[p.pos]
+		switch token.text {
+		case "(":
+			depth++
+		case ")":
+			if depth == 0 {
+				p.pos++
+				if len(item) > 0 {
+					items = append(items, item)
+				}
+				return items, nil
+			}
+			depth--
+		case ",":
+			if depth == 0 {
+				items = append(items, item)
+				item = nil
+				continue
+			}
+		}
+		item = append(item, token)
+	}
+	return nil, fmt.Errorf("unclosed parenthesis")
+}
+
+// columnNames consumes a parenthesized list of (indexed) columns and returns
+// their names, dropping any COLLATE, ASC/DESC or prefix length that follows
+// them.
+func (p *ddlParser) columnNames() ([]string, error) {
+	items, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = unquoteIdentifier(item[0].text)
+	}
+	return names, nil
+}
+
+// conflictClause consumes SQLite's optional ON CONFLICT clause.
+func (p *ddlParser) conflictClause() {
+	if p.accept("ON", "CONFLICT") {
+		p.next()
+	}
+}
+
+// foreignKeyClause consumes the REFERENCES clause of a foreign key.
+func (p *ddlParser) foreignKeyClause(constraint *TableConstraint) error {
+	var err error
+	constraint.ReferencesSchema, constraint.ReferencesTable, err = p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if p.dialect == "sqlite3" {
+		constraint.ReferencesSchema = ""
+	}
+	if p.peek("(") {
+		constraint.ReferencesColumns, err = p.columnNames()
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		switch {
+		case p.accept("ON", "UPDATE"):
+			constraint.OnUpdate, err = p.refOption()
+		case p.accept("ON", "DELETE"):
+			constraint.OnDelete, err = p.refOption()
+		case p.accept("MATCH"):
+			_, err = p.next()
+		case p.accept("NOT", "DEFERRABLE"), p.accept("DEFERRABLE"):
+			if p.accept("INITIALLY") {
+				_, err = p.next()
+			}
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *ddlParser) refOption() (sql.NullString, error) {
+	for _, option := range []RefOption{NoAction, Cascade, Restrict, SetNull, SetDefault} {
+		if p.accept(strings.Fields(string(option))...) {
+			return sql.NullString{String: string(option), Valid: true}, nil
+		}
+	}
+	return sql.NullString{}, fmt.Errorf("unknown foreign key action near %q", p.near())
+}
+
+// The values of Column.Autoincrement.
+const (
+	autoincrementNone = iota
+	autoincrementRowid
+	autoincrementRowidAutoincrement
+	autoincrementIdentity // GENERATED BY DEFAULT AS IDENTITY
+	autoincrementSerial
+	autoincrementAutoIncrement
+	autoincrementAlwaysIdentity // GENERATED ALWAYS AS IDENTITY
+)
+
+// parseCreateTable parses a CREATE TABLE statement into its table name,
+// columns and table constraints, along with the text of each column's
+// definition and the position of the parenthesis that closes the
+// definitions. Constraints that were not named are named following Postgres'
+// naming convention, and CHECK constraints declared on a column are returned
+// as table constraints. MySQL indexes declared within the statement are
+// returned as indexes.
+func parseCreateTable(dialect string, query string, tokens []ddlToken) (table ddlTable, err error) {
+	p := &ddlParser{dialect: dialect, query: query, tokens: tokens}
+	if err = p.expect("CREATE"); err != nil {
+		return table, err
+	}
+	if !p.accept("TEMP") {
+		p.accept("TEMPORARY")
+	}
+	if err = p.expect("TABLE"); err != nil {
+		return table, err
+	}
+	p.accept("IF", "NOT", "EXISTS")
+	table.tableName[0], table.tableName[1], err = p.qualifiedName()
+	if err != nil {
+		return table, err
+	}
+	if p.peek("AS") {
+		return table, fmt.Errorf("CREATE TABLE ... AS SELECT has no column definitions")
+	}
+	definitions, err := p.list()
+	if err != nil {
+		return table, err
+	}
+	table.closingParen = p.tokens[p.pos-1].start
+	table.columnDefinitions = make(map[string]string)
+	names := make(map[string]bool)
+	for _, definition := range definitions {
+		dp := &ddlParser{dialect: dialect, query: query, tokens: definition}
+		if dp.isIndexDefinition() {
+			index, err := dp.indexDefinition(table.tableName)
+			if err != nil {
+				return table, err
+			}
+			table.indexes = append(table.indexes, index)
+			continue
+		}
+		if dp.isTableConstraint() {
+			constraint, err := dp.tableConstraint(table.tableName)
+			if err != nil {
+				return table, err
+			}
+			if constraint.ConstraintName == "" {
+				constraint.ConstraintName = mysqlConstraintName(table.tableName[1], constraint.ConstraintType, table.constraints)
+			}
+			table.constraints = append(table.constraints, uniqueConstraintName(names, constraint))
+			continue
+		}
+		column, constraints, err := dp.columnDefinition(table.tableName)
+		if err != nil {
+			return table, err
+		}
+		table.columns = append(table.columns, column)
+		table.columnDefinitions[column.ColumnName] = dp.text(0, len(definition))
+		for _, constraint := range constraints {
+			if constraint.ConstraintName == "" {
+				constraint.ConstraintName = mysqlConstraintName(table.tableName[1], constraint.ConstraintType, table.constraints)
+			}
+			table.constraints = append(table.constraints, uniqueConstraintName(names, constraint))
+		}
+	}
+	return table, nil
+}
+
+// uniqueConstraintName numbers a generated constraint name that has already
+// been taken (e.g. film_check, film_check1), like Postgres does.
+func uniqueConstraintName(names map[string]bool, constraint TableConstraint) TableConstraint {
+	name := constraint.ConstraintName
+	for i := 1; names[name]; i++ {
+		name = constraint.ConstraintName + strconv.Itoa(i)
+	}
+	names[name] = true
+	constraint.ConstraintName = name
+	return constraint
+}
+
+func (p *ddlParser) isTableConstraint() bool {
+	return p.peek("CONSTRAINT") || p.peek("PRIMARY") || p.peek("UNIQUE") || p.peek("CHECK") || p.peek("FOREIGN")
+}
+
+// isIndexDefinition reports whether a definition within a MySQL CREATE TABLE
+// is an index.
+func (p *ddlParser) isIndexDefinition() bool {
+	return p.dialect == "mysql" && (p.peek("INDEX") || p.peek("KEY") || p.peek("FULLTEXT") || p.peek("SPATIAL"))
+}
+
+var columnConstraintKeywords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "NOT": true, "NULL": true, "UNIQUE": true, "CHECK": true,
+	"DEFAULT": true, "COLLATE": true, "REFERENCES": true, "GENERATED": true, "AS": true,
+	"AUTO_INCREMENT": true, "ON": true, "COMMENT": true, "CHARSET": true, "KEY": true,
+}
+
+// atColumnConstraint reports whether the next token starts a column
+// constraint, as opposed to continuing a type or an expression.
+func (p *ddlParser) atColumnConstraint() bool {
+	if p.done() {
+		return true
+	}
+	if p.peek("CHARACTER") {
+		return p.pos+1 < len(p.tokens) && strings.EqualFold(p.tokens[p.pos+1].text, "SET")
+	}
+	return columnConstraintKeywords[strings.ToUpper(p.tokens[p.pos].text)]
+}
+
+// skipUntilColumnConstraint consumes tokens up to the next column constraint
+// and returns the position it started from.
+func (p *ddlParser) skipUntilColumnConstraint() (start int, err error) {
+	start = p.pos
+	for !p.atColumnConstraint() {
+		if p.peek("(") {
+			if _, err = p.parenthesized(); err != nil {
+				return start, err
+			}
+			continue
+		}
+		p.pos++
+	}
+	return start, nil
+}
+
+// columnDefinition parses a column definition. CHECK constraints, and the
+// PRIMARY KEY, UNIQUE and REFERENCES constraints that were given a name, are
+// returned as table constraints so that their names are kept.
+func (p *ddlParser) columnDefinition(tableName [2]string) (column Column, constraints []TableConstraint, err error) {
+	column.TableSchema, column.TableName = tableName[0], tableName[1]
+	column.ColumnName, err = p.name()
+	if err != nil {
+		return column, nil, err
+	}
+	start, err := p.skipUntilColumnConstraint()
+	if err != nil {
+		return column, nil, err
+	}
+	column.ColumnType = p.text(start, p.pos)
+	switch strings.ToUpper(column.ColumnType) {
+	case "SERIAL", "BIGSERIAL", "SMALLSERIAL":
+		if p.dialect == "postgres" {
+			column.Autoincrement = autoincrementSerial
+		}
+	}
+	for !p.done() {
+		var constraintName string
+		if p.accept("CONSTRAINT") {
+			if constraintName, err = p.name(); err != nil {
+				return column, nil, err
+			}
+		}
+		switch {
+		case p.accept("PRIMARY", "KEY"):
+			if constraintName != "" && p.dialect != "mysql" {
+				constraints = append(constraints, columnConstraint(tableName, constraintName, "PRIMARY KEY", column.ColumnName))
+			} else {
+				column.IsPrimaryKey = true
+			}
+			if !p.accept("ASC") {
+				p.accept("DESC")
+			}
+			p.conflictClause()
+			if p.dialect == "sqlite3" && strings.EqualFold(column.ColumnType, "INTEGER") {
+				column.Autoincrement = autoincrementRowid
+				if p.accept("AUTOINCREMENT") {
+					column.Autoincrement = autoincrementRowidAutoincrement
+				}
+			}
+		case p.accept("NOT", "NULL"):
+			column.IsNotNull = true
+			p.conflictClause()
+		case p.accept("NULL"):
+			p.conflictClause()
+		case p.accept("UNIQUE"):
+			p.accept("KEY")
+			if constraintName != "" {
+				constraints = append(constraints, columnConstraint(tableName, constraintName, "UNIQUE", column.ColumnName))
+			} else {
+				column.IsUnique = true
+			}
+			p.conflictClause()
+		case p.accept("KEY"):
+			column.IsPrimaryKey = true
+		case p.accept("CHECK"):
+			expr, err := p.parenthesized()
+			if err != nil {
+				return column, nil, err
+			}
+			if constraintName == "" && p.dialect != "mysql" {
+				constraintName = defaultConstraintName(p.dialect, tableName[1], "CHECK", []string{column.ColumnName})
+			}
+			check := columnConstraint(tableName, constraintName, "CHECK", column.ColumnName)
+			check.CheckExpr = sql.NullString{String: expr, Valid: true}
+			constraints = append(constraints, check)
+		case p.accept("DEFAULT"):
+			start, err := p.skipUntilColumnConstraint()
+			if err != nil {
+				return column, nil, err
+			}
+			if start == p.pos && p.peek("NULL") {
+				// NULL is also a column constraint, but right after DEFAULT
+				// it is the value.
+				p.pos++
+				if _, err = p.skipUntilColumnConstraint(); err != nil {
+					return column, nil, err
+				}
+			}
+			if start == p.pos {
+				return column, nil, fmt.Errorf("column %s: DEFAULT has no value", column.ColumnName)
+			}
+			column.ColumnDefault = sql.NullString{String: p.text(start, p.pos), Valid: true}
+			if p.tokens[start].text == "(" && p.pos-start > 1 && p.tokens[p.pos-1].text == ")" {
+				inner := &ddlParser{dialect: p.dialect, query: p.query, tokens: p.tokens[start:p.pos]}
+				if expr, err := inner.parenthesized(); err == nil && inner.done() {
+					column.ColumnDefault.String = expr
+				}
+			}
+		case p.accept("COLLATE"):
+			column.Collation.String, err = p.name()
+			if err != nil {
+				return column, nil, err
+			}
+			column.Collation.Valid = true
+		case p.accept("CHARACTER", "SET"), p.accept("CHARSET"):
+			_, err = p.next()
+			if err != nil {
+				return column, nil, err
+			}
+		case p.accept("REFERENCES"):
+			constraint := columnConstraint(tableName, constraintName, "FOREIGN KEY", column.ColumnName)
+			err = p.foreignKeyClause(&constraint)
+			if err != nil {
+				return column, nil, err
+			}
+			if constraintName != "" {
+				constraints = append(constraints, constraint)
+				continue
+			}
+			if constraint.ReferencesSchema != "" {
+				column.ReferencesSchema = sql.NullString{String: constraint.ReferencesSchema, Valid: true}
+			}
+			column.ReferencesTable = sql.NullString{String: constraint.ReferencesTable, Valid: true}
+			if len(constraint.ReferencesColumns) > 0 {
+				column.ReferencesColumn = sql.NullString{String: constraint.ReferencesColumns[0], Valid: true}
+			}
+			column.ReferencesOnUpdate, column.ReferencesOnDelete = constraint.OnUpdate, constraint.OnDelete
+		case p.accept("GENERATED", "BY", "DEFAULT", "AS", "IDENTITY"), p.accept("GENERATED", "ALWAYS", "AS", "IDENTITY"):
+			column.Autoincrement = autoincrementIdentity
+			if strings.EqualFold(p.tokens[p.pos-3].text, "ALWAYS") {
+				column.Autoincrement = autoincrementAlwaysIdentity
+			}
+			if p.peek("(") {
+				if _, err = p.parenthesized(); err != nil {
+					return column, nil, err
+				}
+			}
+		case p.accept("GENERATED", "ALWAYS", "AS"), p.accept("AS"):
+			column.GeneratedExpr.String, err = p.parenthesized()
+			if err != nil {
+				return column, nil, err
+			}
+			column.GeneratedExpr.Valid = true
+			if p.accept("STORED") {
+				column.GeneratedStored = true
+			} else {
+				p.accept("VIRTUAL")
+			}
+		case p.accept("AUTO_INCREMENT"):
+			column.Autoincrement = autoincrementAutoIncrement
+		case p.accept("ON", "UPDATE", "CURRENT_TIMESTAMP"):
+			if p.peek("(") {
+				if _, err = p.parenthesized(); err != nil {
+					return column, nil, err
+				}
+			}
+			column.OnUpdateCurrentTimestamp = sql.NullBool{Bool: true, Valid: true}
+		case p.accept("COMMENT"):
+			comment, err := p.next()
+			if err != nil {
+				return column, nil, err
+			}
+			column.Comment = sql.NullString{String: unquoteLiteral(comment), Valid: true}
+		default:
+			return column, nil, fmt.Errorf("column %s: unexpected %q", column.ColumnName, p.near())
+		}
+	}
+	return column, constraints, nil
+}
+
+func columnConstraint(tableName [2]string, constraintName string, constraintType string, columnName string) TableConstraint {
+	return TableConstraint{
+		TableSchema:    tableName[0],
+		TableName:      tableName[1],
+		ConstraintName: constraintName,
+		ConstraintType: constraintType,
+		Columns:        []string{columnName},
+	}
+}
+
+// mysqlConstraintName names an unnamed CHECK or FOREIGN KEY the way MySQL
+// does, numbering them within their table (film_chk_1, film_ibfk_1) after
+// the ones in constraints.
+func mysqlConstraintName(tableName string, constraintType string, constraints []TableConstraint) string {
+	prefix := tableName + "_chk_"
+	if constraintType == "FOREIGN KEY" {
+		prefix = tableName + "_ibfk_"
+	}
+	n := 0
+	for _, constraint := range constraints {
+		if !strings.HasPrefix(constraint.ConstraintName, prefix) {
+			continue
+		}
+		if i, err := strconv.Atoi(strings.TrimPrefix(constraint.ConstraintName, prefix)); err == nil && i > n {
+			n = i
+		}
+	}
+	return prefix + strconv.Itoa(n+1)
+}
+
+func unquoteLiteral(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		quote := string(s[0])
+		return strings.ReplaceAll(s[1:len(s)-1], quote+quote, quote)
+	}
+	return s
+}
+
+func (p *ddlParser) tableConstraint(tableName [2]string) (constraint TableConstraint, err error) {
+	constraint.TableSchema, constraint.TableName = tableName[0], tableName[1]
+	if p.accept("CONSTRAINT") {
+		if !p.peek("PRIMARY") && !p.peek("UNIQUE") && !p.peek("CHECK") && !p.peek("FOREIGN") {
+			if constraint.ConstraintName, err = p.name(); err != nil {
+				return constraint, err
+			}
+		}
+	}
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		constraint.ConstraintType = "PRIMARY KEY"
+		constraint.Columns, err = p.columnNames()
+		p.conflictClause()
+	case p.accept("UNIQUE"):
+		constraint.ConstraintType = "UNIQUE"
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+		if !p.peek("(") && p.dialect == "mysql" {
+			if constraint.ConstraintName, err = p.name(); err != nil {
+				return constraint, err
+			}
+		}
+		constraint.Columns, err = p.columnNames()
+		p.conflictClause()
+	case p.accept("CHECK"):
+		constraint.ConstraintType = "CHECK"
+		constraint.CheckExpr.String, err = p.parenthesized()
+		constraint.CheckExpr.Valid = true
+		p.accept("NO", "INHERIT")
+	case p.accept("FOREIGN", "KEY"):
+		constraint.ConstraintType = "FOREIGN KEY"
+		if !p.peek("(") && p.dialect == "mysql" {
+			if constraint.ConstraintName, err = p.name(); err != nil {
+				return constraint, err
+			}
+		}
+		constraint.Columns, err = p.columnNames()
+		if err == nil {
+			err = p.expect("REFERENCES")
+		}
+		if err == nil {
+			err = p.foreignKeyClause(&constraint)
+		}
+	default:
+		return constraint, fmt.Errorf("unexpected %q", p.near())
+	}
+	if err != nil {
+		return constraint, err
+	}
+	p.accept("NOT", "VALID")
+	if !p.done() {
+		return constraint, fmt.Errorf("unexpected %q", p.near())
+	}
+	// MySQL numbers unnamed CHECKs and FOREIGN KEYs within their table, which
+	// is left to the caller.
+	isNumbered := p.dialect == "mysql" && (constraint.ConstraintType == "CHECK" || constraint.ConstraintType == "FOREIGN KEY")
+	if constraint.ConstraintName == "" && !isNumbered {
+		constraint.ConstraintName = defaultConstraintName(p.dialect, tableName[1], constraint.ConstraintType, constraint.Columns)
+	}
+	return constraint, nil
+}
+
+// indexDefinition parses an index declared within a MySQL CREATE TABLE, as
+// in INDEX film_title_idx (title) or FULLTEXT KEY (title, description).
+func (p *ddlParser) indexDefinition(tableName [2]string) (index Index, err error) {
+	index.TableSchema, index.TableName = tableName[0], tableName[1]
+	if p.accept("FULLTEXT") {
+		index.IndexType = "FULLTEXT"
+	} else if p.accept("SPATIAL") {
+		index.IndexType = "SPATIAL"
+	}
+	if !p.accept("INDEX") {
+		p.accept("KEY")
+	}
+	if !p.peek("(") && !p.peek("USING") {
+		if index.IndexName, err = p.name(); err != nil {
+			return index, err
+		}
+	}
+	if p.accept("USING") {
+		if index.IndexType, err = p.next(); err != nil {
+			return index, err
+		}
+	}
+	err = p.keyParts(&index)
+	if err != nil {
+		return index, err
+	}
+	if index.IndexName == "" {
+		index.IndexName = tableName[1] + "_" + strings.Join(index.Columns, "_") + "_idx"
+	}
+	return index, nil
+}
+
+// parseCreateIndex parses a CREATE INDEX statement.
+func parseCreateIndex(dialect string, query string, tokens []ddlToken) (index Index, err error) {
+	p := &ddlParser{dialect: dialect, query: query, tokens: tokens}
+	if err = p.expect("CREATE"); err != nil {
+		return index, err
+	}
+	switch {
+	case p.accept("UNIQUE"):
+		index.IsUnique = true
+	case p.accept("FULLTEXT"):
+		index.IndexType = "FULLTEXT"
+	case p.accept("SPATIAL"):
+		index.IndexType = "SPATIAL"
+	}
+	if err = p.expect("INDEX"); err != nil {
+		return index, err
+	}
+	if p.accept("CONCURRENTLY") {
+		index.Online = true
+	}
+	p.accept("IF", "NOT", "EXISTS")
+	index.IndexSchema, index.IndexName, err = p.qualifiedName()
+	if err != nil {
+		return index, err
+	}
+	if err = p.expect("ON"); err != nil {
+		return index, err
+	}
+	p.accept("ONLY")
+	index.TableSchema, index.TableName, err = p.qualifiedName()
+	if err != nil {
+		return index, err
+	}
+	if dialect == "sqlite3" {
+		index.TableSchema = index.IndexSchema
+	}
+	for !p.done() {
+		switch {
+		case p.accept("USING"):
+			indexType, err := p.next()
+			if err != nil {
+				return index, err
+			}
+			index.IndexType = strings.ToUpper(indexType)
+		case p.peek("("):
+			if err = p.keyParts(&index); err != nil {
+				return index, err
+			}
+		case p.accept("INCLUDE"):
+			if index.Include, err = p.columnNames(); err != nil {
+				return index, err
+			}
+		case p.accept("WHERE"):
+			index.IsPartial = true
+			index.Where = p.text(p.pos, len(p.tokens))
+			p.pos = len(p.tokens)
+		case p.accept("ALGORITHM"), p.accept("LOCK"):
+			p.accept("=")
+			if _, err = p.next(); err != nil {
+				return index, err
+			}
+			index.Online = true
+		default:
+			return index, fmt.Errorf("index %s: unexpected %q", index.IndexName, p.near())
+		}
+	}
+	if len(index.Columns) == 0 {
+		return index, fmt.Errorf("index %s has no columns", index.IndexName)
+	}
+	return index, nil
+}
+
+// keyParts consumes the parenthesized key parts of an index.
+func (p *ddlParser) keyParts(index *Index) error {
+	items, err := p.list()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		kp := &ddlParser{dialect: p.dialect, query: p.query, tokens: item}
+		end := len(item)
+		last := func(keyword string) bool {
+			return end > 1 && strings.EqualFold(item[end-1].text, keyword)
+		}
+		var direction, nullsOrder, opclass, collation string
+		if end > 2 && strings.EqualFold(item[end-2].text, "NULLS") && (last("FIRST") || last("LAST")) {
+			nullsOrder = "NULLS " + strings.ToUpper(item[end-1].text)
+			end -= 2
+		}
+		if last("ASC") || last("DESC") {
+			direction = strings.ToUpper(item[end-1].text)
+			end--
+		}
+		// A Postgres operator class is a name that follows the key part.
+		if p.dialect == "postgres" && end > 1 && !strings.EqualFold(item[end-2].text, "COLLATE") {
+			if _, ok := sqliteIdentifier(item[end-1].text); ok {
+				if prev := item[end-2].text; prev == ")" || isIdentifierToken(prev) {
+					opclass = item[end-1].text
+					end--
+				}
+			}
+		}
+		if end > 2 && strings.EqualFold(item[end-2].text, "COLLATE") {
+			collation = unquoteIdentifier(item[end-1].text)
+			end -= 2
+		}
+		var column, expr string
+		var prefixLength int
+		switch {
+		case end == 1 && isIdentifierToken(item[0].text):
+			column = unquoteIdentifier(item[0].text)
+		case end == 4 && p.dialect == "mysql" && item[1].text == "(" && item[3].text == ")":
+			column = unquoteIdentifier(item[0].text)
+			prefixLength, err = strconv.Atoi(item[2].text)
+			if err != nil {
+				return fmt.Errorf("invalid prefix length %s", item[2].text)
+			}
+		default:
+			kp.tokens = item[:end]
+			expr = kp.text(0, end)
+			if item[0].text == "(" {
+				if inner, err := kp.parenthesized(); err == nil && kp.done() {
+					expr = inner
+				}
+			}
+		}
+		index.Columns = append(index.Columns, column)
+		index.Exprs = append(index.Exprs, expr)
+		index.Directions = append(index.Directions, direction)
+		index.NullsOrders = append(index.NullsOrders, nullsOrder)
+		index.Opclasses = append(index.Opclasses, opclass)
+		index.Collations = append(index.Collations, collation)
+		index.PrefixLengths = append(index.PrefixLengths, prefixLength)
+	}
+	return nil
+}
+
+// isIdentifierToken reports whether a token is a plain or quoted identifier.
+func isIdentifierToken(token string) bool {
+	if token == "" || token[0] == '\'' || (token[0] >= '0' && token[0] <= '9') {
+		return false
+	}
+	_, ok := sqliteIdentifier(token)
+	return ok
+}
+
+// unquoteIdentifier removes the quotes from a quoted identifier.
+func unquoteIdentifier(token string) string {
+	name, ok := sqliteIdentifier(token)
+	if !ok {
+		return token
+	}
+	return name
+}
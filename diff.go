@@ -0,0 +1,40 @@
+package metadata
+
+import "database/sql"
+
+// ColumnMismatches compares a column in the database against the column
+// that is wanted and returns which of its stats do not match: type, notnull,
+// default, generated, collation, references or comment. Each mismatch can then be
+// handed to its resolver.
+func ColumnMismatches(dialect string, gotColumn, wantColumn Column) (mismatches []string) {
+	if wantColumn.ColumnType != "" && normalizeType(gotColumn.ColumnType) != normalizeType(wantColumn.ColumnType) {
+		mismatches = append(mismatches, "type")
+	}
+	if gotColumn.IsNotNull != wantColumn.IsNotNull {
+		mismatches = append(mismatches, "notnull")
+	}
+	if !exprEqual(gotColumn.ColumnDefault, wantColumn.ColumnDefault) {
+		mismatches = append(mismatches, "default")
+	}
+	if !exprEqual(gotColumn.GeneratedExpr, wantColumn.GeneratedExpr) || (wantColumn.GeneratedExpr.Valid && gotColumn.GeneratedStored != wantColumn.GeneratedStored) {
+		mismatches = append(mismatches, "generated")
+	}
+	if !collationEqual(dialect, gotColumn.Collation, wantColumn.Collation) {
+		mismatches = append(mismatches, "collation")
+	}
+	if !exprEqual(gotColumn.ReferencesTable, wantColumn.ReferencesTable) ||
+		(wantColumn.ReferencesColumn.Valid && !exprEqual(gotColumn.ReferencesColumn, wantColumn.ReferencesColumn)) {
+		mismatches = append(mismatches, "references")
+	}
+	if wantColumn.Comment.Valid && dialect != "sqlite3" && gotColumn.Comment.String != wantColumn.Comment.String {
+		mismatches = append(mismatches, "comment")
+	}
+	return mismatches
+}
+
+func exprEqual(got, want sql.NullString) bool {
+	if got.Valid != want.Valid {
+		return false
+	}
+	return normalizeExpr(got.String) == normalizeExpr(want.String)
+}
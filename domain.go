@@ -0,0 +1,285 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Domain is a Postgres domain, a base type with an optional default, NOT
+// NULL and CHECK constraints. Checks maps each constraint name to its
+// expression, which refers to the value being checked as VALUE. Other
+// dialects emulate a domain by inlining its base type, default, NOT NULL and
+// checks into every column that uses it.
+type Domain struct {
+	DomainSchema string
+	DomainName   string
+	BaseType     string
+	Default      sql.NullString
+	IsNotNull    bool
+	Checks       map[string]string
+}
+
+func createDomainQuery(domain Domain) string {
+	buf := &strings.Builder{}
+	buf.WriteString("CREATE DOMAIN " + qualifiedName("postgres", domain.DomainSchema, domain.DomainName) + " AS " + domain.BaseType)
+	if domain.Default.Valid {
+		buf.WriteString(" DEFAULT " + domain.Default.String)
+	}
+	if domain.IsNotNull {
+		buf.WriteString(" NOT NULL")
+	}
+	for _, name := range sortedKeys(domain.Checks) {
+		buf.WriteString(" CONSTRAINT " + quoteIdentifier("postgres", name) + " CHECK (" + domain.Checks[name] + ")")
+	}
+	return buf.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AlterDomainQueries returns the ALTER DOMAIN queries that evolve gotDomain
+// into wantDomain. Postgres cannot change the base type of a domain, so that
+// is reported as an error.
+func AlterDomainQueries(gotDomain, wantDomain Domain) (querylist []string, err error) {
+	domainName := qualifiedName("postgres", wantDomain.DomainSchema, wantDomain.DomainName)
+	if normalizeType(gotDomain.BaseType) != normalizeType(wantDomain.BaseType) {
+		return nil, fmt.Errorf("domain %s: cannot change base type from %s to %s", wantDomain.DomainName, gotDomain.BaseType, wantDomain.BaseType)
+	}
+	switch {
+	case wantDomain.Default.Valid && (!gotDomain.Default.Valid || normalizeExpr(gotDomain.Default.String) != normalizeExpr(wantDomain.Default.String)):
+		querylist = append(querylist, "ALTER DOMAIN "+domainName+" SET DEFAULT "+wantDomain.Default.String)
+	case !wantDomain.Default.Valid && gotDomain.Default.Valid:
+		querylist = append(querylist, "ALTER DOMAIN "+domainName+" DROP DEFAULT")
+	}
+	if wantDomain.IsNotNull != gotDomain.IsNotNull {
+		if wantDomain.IsNotNull {
+			querylist = append(querylist, "ALTER DOMAIN "+domainName+" SET NOT NULL")
+		} else {
+			querylist = append(querylist, "ALTER DOMAIN "+domainName+" DROP NOT NULL")
+		}
+	}
+	for _, name := range sortedKeys(gotDomain.Checks) {
+		wantExpr, ok := wantDomain.Checks[name]
+		if !ok || normalizeExpr(wantExpr) != normalizeExpr(gotDomain.Checks[name]) {
+			querylist = append(querylist, "ALTER DOMAIN "+domainName+" DROP CONSTRAINT "+quoteIdentifier("postgres", name))
+		}
+	}
+	for _, name := range sortedKeys(wantDomain.Checks) {
+		gotExpr, ok := gotDomain.Checks[name]
+		if !ok || normalizeExpr(gotExpr) != normalizeExpr(wantDomain.Checks[name]) {
+			querylist = append(querylist, "ALTER DOMAIN "+domainName+" ADD CONSTRAINT "+quoteIdentifier("postgres", name)+" CHECK ("+wantDomain.Checks[name]+")")
+		}
+	}
+	return querylist, nil
+}
+
+// GetDomains returns the domains in a Postgres database.
+func GetDomains(db DB) (domains map[[2]string]Domain, err error) {
+	rows, err := db.Query(`SELECT n.nspname, t.typname, format_type(t.typbasetype, t.typtypmod), t.typdefault, t.typnotnull
+	,COALESCE(c.conname, ''), COALESCE(pg_get_constraintdef(c.oid), '')
+FROM pg_type AS t
+JOIN pg_namespace AS n ON n.oid = t.typnamespace
+LEFT JOIN pg_constraint AS c ON c.contypid = t.oid AND c.contype = 'c'
+WHERE t.typtype = 'd' AND n.nspname NOT IN ('pg_catalog', 'information_schema')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	domains = make(map[[2]string]Domain)
+	for rows.Next() {
+		var domain Domain
+		var checkName, checkDef string
+		err = rows.Scan(&domain.DomainSchema, &domain.DomainName, &domain.BaseType, &domain.Default, &domain.IsNotNull, &checkName, &checkDef)
+		if err != nil {
+			return nil, err
+		}
+		key := [2]string{domain.DomainSchema, domain.DomainName}
+		if existing, ok := domains[key]; ok {
+			domain = existing
+		}
+		if domain.Checks == nil {
+			domain.Checks = make(map[string]string)
+		}
+		if checkName != "" {
+			domain.Checks[checkName] = strings.TrimPrefix(checkDef, "CHECK ")
+		}
+		domains[key] = domain
+	}
+	return domains, rows.Err()
+}
+
+// DomainGetter is implemented by WantTables that declare domains.
+// EnsureTables passes them to EnsureDomains after the enums of an EnumGetter
+// and before creating any table.
+type DomainGetter interface {
+	GetDomains() (domains []Domain, err error)
+}
+
+// sortDomains orders domains so that a domain based on another domain comes
+// after it, keeping the given order otherwise.
+func sortDomains(domains []Domain) ([]Domain, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(domains))
+	sorted := make([]Domain, 0, len(domains))
+	var visit func(i int) error
+	visit = func(i int) error {
+		state[i] = visiting
+		schema, name := splitTypeName(domains[i].BaseType)
+		for j, domain := range domains {
+			if !strings.EqualFold(domain.DomainName, name) || (schema != "" && domain.DomainSchema != "" && !strings.EqualFold(domain.DomainSchema, schema)) {
+				continue
+			}
+			switch state[j] {
+			case visiting:
+				return fmt.Errorf("domain %s: base type %s forms a cycle", domains[i].DomainName, domains[i].BaseType)
+			case unvisited:
+				err := visit(j)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = visited
+		sorted = append(sorted, domains[i])
+		return nil
+	}
+	for i := range domains {
+		if state[i] != unvisited {
+			continue
+		}
+		err := visit(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// splitTypeName splits a type such as "public"."year"[] into its unquoted
+// schema and name.
+func splitTypeName(typ string) (schema, name string) {
+	typ = strings.TrimSpace(typ)
+	for strings.HasSuffix(typ, "[]") {
+		typ = strings.TrimSpace(strings.TrimSuffix(typ, "[]"))
+	}
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		typ = strings.TrimSpace(typ[:i])
+	}
+	if i := strings.LastIndexByte(typ, '.'); i >= 0 {
+		schema, typ = strings.Trim(typ[:i], `"`), typ[i+1:]
+	}
+	return schema, strings.Trim(typ, `"`)
+}
+
+// EnsureDomains creates the domains that do not exist yet and alters the ones
+// that differ, creating a domain based on another domain after it. It must be
+// called after EnsureEnums (a domain may be based on an enum) and before the
+// tables that use the domains are created (EnsureTables does so for a
+// DomainGetter), and does nothing outside of Postgres.
+func EnsureDomains(db DB, dialect string, domains []Domain) error {
+	if dialect != "postgres" {
+		return nil
+	}
+	domains, err := sortDomains(domains)
+	if err != nil {
+		return err
+	}
+	gotDomains, err := GetDomains(db)
+	if err != nil {
+		return err
+	}
+	defaultSchema, err := DefaultSchema(db, dialect)
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		schema := domain.DomainSchema
+		if schema == "" {
+			schema = defaultSchema
+		}
+		var querylist []string
+		if gotDomain, ok := gotDomains[[2]string{schema, domain.DomainName}]; ok {
+			querylist, err = AlterDomainQueries(gotDomain, domain)
+			if err != nil {
+				return err
+			}
+		} else {
+			querylist = []string{createDomainQuery(domain)}
+		}
+		for _, query := range querylist {
+			_, err = db.Exec(query)
+			if err != nil {
+				return fmt.Errorf("%s: %w", query, err)
+			}
+		}
+	}
+	return nil
+}
+
+// inlineDomain emulates a domain on dialects that do not have one by copying
+// its base type, default and NOT NULL into a column and turning its checks
+// into CHECK constraints on the column's table.
+func inlineDomain(dialect string, column Column, domain Domain) (Column, []TableConstraint) {
+	if dialect == "postgres" {
+		column.ColumnType = qualifiedName(dialect, domain.DomainSchema, domain.DomainName)
+		return column, nil
+	}
+	column.ColumnType = domain.BaseType
+	if !column.ColumnDefault.Valid {
+		column.ColumnDefault = domain.Default
+	}
+	column.IsNotNull = column.IsNotNull || domain.IsNotNull
+	var constraints []TableConstraint
+	for _, name := range sortedKeys(domain.Checks) {
+		constraint := TableConstraint{
+			TableSchema:    column.TableSchema,
+			TableName:      column.TableName,
+			ConstraintName: column.TableName + "_" + column.ColumnName + "_check",
+			ConstraintType: "CHECK",
+			Columns:        []string{column.ColumnName},
+		}
+		if len(domain.Checks) > 1 {
+			constraint.ConstraintName = column.TableName + "_" + column.ColumnName + "_" + name
+		}
+		constraint.CheckExpr.String = replaceValueKeyword(domain.Checks[name], quoteIdentifier(dialect, column.ColumnName))
+		constraint.CheckExpr.Valid = true
+		constraints = append(constraints, constraint)
+	}
+	return column, constraints
+}
+
+// replaceValueKeyword replaces every VALUE keyword outside of string literals
+// in a domain check expression with replacement.
+func replaceValueKeyword(expr string, replacement string) string {
+	isIdentRune := func(r byte) bool {
+		return r == '_' || unicode.IsLetter(rune(r)) || unicode.IsDigit(rune(r))
+	}
+	buf := &strings.Builder{}
+	inString := false
+	for i := 0; i < len(expr); i++ {
+		if expr[i] == '\'' {
+			inString = !inString
+		}
+		if !inString && i+5 <= len(expr) && strings.EqualFold(expr[i:i+5], "VALUE") &&
+			(i == 0 || !isIdentRune(expr[i-1])) && (i+5 == len(expr) || !isIdentRune(expr[i+5])) {
+			buf.WriteString(replacement)
+			i += 4
+			continue
+		}
+		buf.WriteByte(expr[i])
+	}
+	return buf.String()
+}
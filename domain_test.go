@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestDomain(t *testing.T) {
+	is := testutil.New(t)
+	is.Equal("CREATE DOMAIN year AS INT CONSTRAINT year_check CHECK (VALUE >= 1901 AND VALUE <= 2155)", createDomainQuery(yearDomain))
+
+	column, constraints := inlineDomain("sqlite3", Column{TableName: "film", ColumnName: "release_year"}, yearDomain)
+	is.Equal("INT", column.ColumnType)
+	is.Equal(1, len(constraints))
+	is.Equal("film_release_year_check", constraints[0].ConstraintName)
+	is.Equal("release_year >= 1901 AND release_year <= 2155", constraints[0].CheckExpr.String)
+	definition, err := constraintDefinition("sqlite3", constraints[0])
+	is.NoErr(err)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE film (" + columnDefinition("sqlite3", column) + ", " + definition + ")")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO film (release_year) VALUES (2006)")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO film (release_year) VALUES (1800)")
+	is.True(err != nil)
+
+	is.Equal("'VALUE' = x AND (x IS NOT NULL)", replaceValueKeyword("'VALUE' = value AND (VALUE IS NOT NULL)", "x"))
+	is.Equal("value_count > 0", replaceValueKeyword("value_count > 0", "x"))
+}
+
+func TestAlterDomainQueries(t *testing.T) {
+	is := testutil.New(t)
+	gotDomain := Domain{
+		DomainSchema: "public",
+		DomainName:   "year",
+		BaseType:     "integer",
+		IsNotNull:    true,
+		Checks:       map[string]string{"year_check": "((VALUE >= 1901) AND (VALUE <= 2155))"},
+	}
+	querylist, err := AlterDomainQueries(gotDomain, yearDomain)
+	is.NoErr(err)
+	is.Equal([]string{"ALTER DOMAIN year DROP NOT NULL"}, querylist)
+
+	wantDomain := yearDomain
+	wantDomain.Default = sql.NullString{String: "2000", Valid: true}
+	wantDomain.Checks = map[string]string{"year_check": "VALUE >= 1800 AND VALUE <= 2155"}
+	gotDomain.IsNotNull = false
+	querylist, err = AlterDomainQueries(gotDomain, wantDomain)
+	is.NoErr(err)
+	is.Equal([]string{
+		"ALTER DOMAIN year SET DEFAULT 2000",
+		"ALTER DOMAIN year DROP CONSTRAINT year_check",
+		"ALTER DOMAIN year ADD CONSTRAINT year_check CHECK (VALUE >= 1800 AND VALUE <= 2155)",
+	}, querylist)
+
+	wantDomain.BaseType = "BIGINT"
+	_, err = AlterDomainQueries(gotDomain, wantDomain)
+	is.True(err != nil)
+}
+
+func TestSortDomains(t *testing.T) {
+	is := testutil.New(t)
+	leapYear := Domain{DomainName: "leap_year", BaseType: `"public"."year"`}
+	years := Domain{DomainName: "years", BaseType: "leap_year[]"}
+	domains, err := sortDomains([]Domain{years, leapYear, yearDomain})
+	is.NoErr(err)
+	var names []string
+	for _, domain := range domains {
+		names = append(names, domain.DomainName)
+	}
+	is.Equal([]string{"year", "leap_year", "years"}, names)
+
+	_, err = sortDomains([]Domain{
+		{DomainName: "a", BaseType: "b"},
+		{DomainName: "b", BaseType: "a"},
+	})
+	is.True(err != nil)
+}
@@ -0,0 +1,221 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnsureTables brings the database in line with wantTables without dropping
+// anything: it creates missing schemas, creates the tables that do not exist
+// yet (in dependency order, adding references that form a cycle once every
+// table exists), adds the columns missing from existing tables and creates
+// missing indexes through EnsureIndex. The only thing it drops is an INVALID
+// index left behind by a failed CREATE INDEX CONCURRENTLY on Postgres, which
+// it builds again. Tables without a schema are created in the default schema
+// (see DefaultSchema and SearchPathQuery). Columns that exist but differ are
+// left alone; see ColumnMismatches.
+//
+// On Postgres and MySQL, references that form a cycle can only be created if
+// wantTables is a ForeignKeyDeferrer. If wantTables is an EnumGetter or a
+// DomainGetter, its enums and then its domains are ensured before any table
+// is created.
+func EnsureTables(db DB, dialect string, wantTables WantTables) error {
+	err := EnsureSchemas(db, dialect, wantTables)
+	if err != nil {
+		return err
+	}
+	if enumGetter, ok := wantTables.(EnumGetter); ok {
+		enums, err := enumGetter.GetEnums()
+		if err != nil {
+			return err
+		}
+		err = EnsureEnums(db, dialect, enums)
+		if err != nil {
+			return err
+		}
+	}
+	if domainGetter, ok := wantTables.(DomainGetter); ok {
+		domains, err := domainGetter.GetDomains()
+		if err != nil {
+			return err
+		}
+		err = EnsureDomains(db, dialect, domains)
+		if err != nil {
+			return err
+		}
+	}
+	defaultSchema, err := DefaultSchema(db, dialect)
+	if err != nil {
+		return err
+	}
+	resolve := func(tableName [2]string) [2]string {
+		if tableName[0] == "" {
+			tableName[0] = defaultSchema
+		}
+		return tableName
+	}
+	tableNames, deferred, err := SortTables(wantTables)
+	if err != nil {
+		return err
+	}
+	if dialect == "sqlite3" {
+		deferred = nil
+	}
+	deferredNames := make(map[[2]string][]string)
+	for _, constraint := range deferred {
+		tableName := resolve([2]string{constraint.TableSchema, constraint.TableName})
+		deferredNames[tableName] = append(deferredNames[tableName], constraint.ConstraintName)
+	}
+	created := make(map[[2]string]bool)
+	for _, tableName := range tableNames {
+		gotColumns, err := getColumnNames(db, dialect, resolve(tableName))
+		if err != nil {
+			return err
+		}
+		if len(gotColumns) == 0 {
+			var querylist []string
+			var argslist [][]interface{}
+			if names := deferredNames[resolve(tableName)]; len(names) > 0 {
+				deferrer, ok := wantTables.(ForeignKeyDeferrer)
+				if !ok {
+					return fmt.Errorf("table %s: foreign keys %s form a cycle and cannot be left out of CREATE TABLE", tableName[1], strings.Join(names, ", "))
+				}
+				querylist, argslist, err = deferrer.CreateTableWithoutForeignKeys(tableName, names)
+			} else {
+				querylist, argslist, err = wantTables.CreateTable(tableName)
+			}
+			if err != nil {
+				return err
+			}
+			for i, query := range querylist {
+				var args []interface{}
+				if i < len(argslist) {
+					args = argslist[i]
+				}
+				_, err = db.Exec(query, args...)
+				if err != nil {
+					return fmt.Errorf("%s: %w", query, err)
+				}
+			}
+			created[resolve(tableName)] = true
+			continue
+		}
+		wantColumns, err := wantTables.GetColumns(tableName)
+		if err != nil {
+			return err
+		}
+		columnNames := make([]string, 0, len(wantColumns))
+		for columnName := range wantColumns {
+			columnNames = append(columnNames, columnName)
+		}
+		sort.Strings(columnNames)
+		for _, columnName := range columnNames {
+			if gotColumns[columnName] {
+				continue
+			}
+			query, args, err := wantTables.CreateColumn(tableName, columnName)
+			if err != nil {
+				return err
+			}
+			_, err = db.Exec(query, args...)
+			if err != nil {
+				return fmt.Errorf("%s: %w", query, err)
+			}
+		}
+		err = ensureMissingIndexes(db, dialect, wantTables, tableName)
+		if err != nil {
+			return err
+		}
+	}
+	for _, constraint := range deferred {
+		if !created[resolve([2]string{constraint.TableSchema, constraint.TableName})] {
+			continue
+		}
+		query, err := AddConstraintQuery(dialect, constraint)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
+
+func ensureMissingIndexes(db DB, dialect string, wantTables WantTables, tableName [2]string) error {
+	wantIndexes, err := wantTables.GetIndices(tableName)
+	if err != nil {
+		return err
+	}
+	if len(wantIndexes) == 0 {
+		return nil
+	}
+	gotIndexes, err := GetIndexes(db, dialect, tableName)
+	if err != nil {
+		return err
+	}
+	indexNames := make([][2]string, 0, len(wantIndexes))
+	for indexName := range wantIndexes {
+		indexNames = append(indexNames, indexName)
+	}
+	sort.Slice(indexNames, func(i, j int) bool { return indexNames[i][1] < indexNames[j][1] })
+	for _, indexName := range indexNames {
+		index := wantIndexes[indexName]
+		if _, ok := gotIndexes[indexName[1]]; ok {
+			if dialect != "postgres" {
+				continue
+			}
+			// An INVALID index left behind by a failed CREATE INDEX
+			// CONCURRENTLY is rebuilt by EnsureIndex.
+			isInvalid, err := isInvalidIndex(db, index)
+			if err != nil {
+				return err
+			}
+			if !isInvalid {
+				continue
+			}
+		}
+		err = EnsureIndex(db, dialect, index)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getColumnNames returns the names of a table's columns in the database, or
+// nothing if the table does not exist.
+func getColumnNames(db DB, dialect string, tableName [2]string) (map[string]bool, error) {
+	var query string
+	var args []interface{}
+	switch dialect {
+	case "postgres":
+		query = "SELECT column_name FROM information_schema.columns WHERE table_schema = COALESCE(NULLIF($1, ''), current_schema()) AND table_name = $2"
+		args = []interface{}{tableName[0], tableName[1]}
+	case "mysql":
+		query = "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?"
+		args = []interface{}{tableName[0], tableName[1]}
+	case "sqlite3":
+		query = "SELECT name FROM pragma_table_xinfo(?, COALESCE(NULLIF(?, ''), 'main'))"
+		args = []interface{}{tableName[1], tableName[0]}
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", query, err)
+	}
+	defer rows.Close()
+	columnNames := make(map[string]bool)
+	for rows.Next() {
+		var columnName string
+		err = rows.Scan(&columnName)
+		if err != nil {
+			return nil, err
+		}
+		columnNames[columnName] = true
+	}
+	return columnNames, rows.Err()
+}
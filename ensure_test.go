@@ -0,0 +1,131 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestEnsureTables(t *testing.T) {
+	is := testutil.New(t)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	country, city := [2]string{"main", "country"}, [2]string{"main", "city"}
+	wantTables := mockTables{
+		tables: [][2]string{city, country},
+		columns: map[[2]string]map[string]Column{
+			country: {"country_id": {TableName: "country", ColumnName: "country_id"}},
+			city: {
+				"city_id": {TableName: "city", ColumnName: "city_id"},
+				"country_id": {
+					TableName:        "city",
+					ColumnName:       "country_id",
+					ReferencesTable:  sql.NullString{String: "country", Valid: true},
+					ReferencesSchema: sql.NullString{String: "main", Valid: true},
+				},
+			},
+		},
+		createTable: map[[2]string][]string{
+			country: {"CREATE TABLE country (country_id INTEGER PRIMARY KEY)"},
+			city:    {"CREATE TABLE city (city_id INTEGER PRIMARY KEY, country_id INT REFERENCES country)"},
+		},
+	}
+	is.NoErr(EnsureTables(db, "sqlite3", wantTables))
+
+	wantTables.columns[city]["name"] = Column{TableName: "city", ColumnName: "name", ColumnType: "TEXT"}
+	wantTables.indices = map[[2]string]map[[2]string]Index{
+		city: {{"", "city_name_idx"}: {TableName: "city", IndexName: "city_name_idx", Columns: []string{"name"}}},
+	}
+	is.NoErr(EnsureTables(db, "sqlite3", wantTables))
+	columnNames, err := getColumnNames(db, "sqlite3", city)
+	is.NoErr(err)
+	is.Equal(map[string]bool{"city_id": true, "country_id": true, "name": true}, columnNames)
+	indexes, err := GetIndexes(db, "sqlite3", city)
+	is.NoErr(err)
+	is.Equal([]string{"name"}, indexes["city_name_idx"].Columns)
+	is.NoErr(EnsureTables(db, "sqlite3", wantTables))
+}
+
+func TestSchemaQueries(t *testing.T) {
+	is := testutil.New(t)
+	is.Equal("CREATE SCHEMA IF NOT EXISTS tenant_1", createSchemaQuery("postgres", "tenant_1"))
+	is.Equal("CREATE DATABASE IF NOT EXISTS `tenant-1`", createSchemaQuery("mysql", "tenant-1"))
+	is.Equal("", createSchemaQuery("sqlite3", "tenant_1"))
+	query, err := SearchPathQuery("postgres", "tenant_1", "public")
+	is.NoErr(err)
+	is.Equal("SET search_path TO tenant_1, public", query)
+	_, err = SearchPathQuery("mysql", "tenant_1", "public")
+	is.True(err != nil)
+}
+
+func TestEnsureTablesCycle(t *testing.T) {
+	is := testutil.New(t)
+	staff, store := [2]string{"app", "staff"}, [2]string{"app", "store"}
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("ATTACH DATABASE ':memory:' AS app")
+	is.NoErr(err)
+	sq := mockTables{
+		tables: [][2]string{staff, store},
+		columns: map[[2]string]map[string]Column{
+			staff: {
+				"staff_id": {TableSchema: "app", TableName: "staff", ColumnName: "staff_id"},
+				"store_id": {TableSchema: "app", TableName: "staff", ColumnName: "store_id", ReferencesTable: sql.NullString{String: "store", Valid: true}},
+			},
+			store: {
+				"store_id":         {TableSchema: "app", TableName: "store", ColumnName: "store_id"},
+				"manager_staff_id": {TableSchema: "app", TableName: "store", ColumnName: "manager_staff_id", ReferencesTable: sql.NullString{String: "staff", Valid: true}},
+			},
+		},
+		createTable: map[[2]string][]string{
+			staff: {"CREATE TABLE app.staff (staff_id INTEGER PRIMARY KEY, store_id INT NOT NULL REFERENCES store (store_id))"},
+			store: {"CREATE TABLE app.store (store_id INTEGER PRIMARY KEY, manager_staff_id INT NOT NULL REFERENCES staff (staff_id))"},
+		},
+	}
+	is.NoErr(EnsureTables(db, "sqlite3", sq))
+	is.NoErr(EnsureTables(db, "sqlite3", sq))
+	columnNames, err := getColumnNames(db, "sqlite3", store)
+	is.NoErr(err)
+	is.Equal(map[string]bool{"store_id": true, "manager_staff_id": true}, columnNames)
+	columnNames, err = getColumnNames(db, "sqlite3", [2]string{"", "store"})
+	is.NoErr(err)
+	is.Equal(0, len(columnNames))
+}
+
+type enumTables struct {
+	mockTables
+	enums []Enum
+	err   error
+}
+
+func (e enumTables) GetEnums() (enums []Enum, err error) { return e.enums, e.err }
+
+func TestEnsureTablesEnums(t *testing.T) {
+	is := testutil.New(t)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	film := [2]string{"main", "film"}
+	wantTables := enumTables{
+		mockTables: mockTables{
+			tables:      [][2]string{film},
+			columns:     map[[2]string]map[string]Column{film: {"rating": {TableName: "film", ColumnName: "rating"}}},
+			createTable: map[[2]string][]string{film: {"CREATE TABLE film (rating TEXT)"}},
+		},
+		err: fmt.Errorf("no enums"),
+	}
+	is.True(EnsureTables(db, "sqlite3", wantTables) != nil)
+	columnNames, err := getColumnNames(db, "sqlite3", film)
+	is.NoErr(err)
+	is.Equal(0, len(columnNames))
+	wantTables.err = nil
+	wantTables.enums = []Enum{{EnumName: "mpaa_rating", Values: []string{"G", "PG"}}}
+	is.NoErr(EnsureTables(db, "sqlite3", wantTables))
+}
@@ -0,0 +1,211 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Enum is a Postgres enum type. MySQL has no standalone enum types so the
+// values are inlined into an ENUM(...) column type instead, and SQLite falls
+// back to a CHECK constraint.
+type Enum struct {
+	EnumSchema string
+	EnumName   string
+	Values     []string
+}
+
+// EnumGetter is implemented by WantTables that declare enums. EnsureTables
+// passes them to EnsureEnums before creating any table.
+type EnumGetter interface {
+	GetEnums() (enums []Enum, err error)
+}
+
+// enumFromModifier parses an enum tag modifier value such as
+// {mpaa_rating values=G,PG,PG-13,R,NC-17}.
+func enumFromModifier(value string) (Enum, error) {
+	var enum Enum
+	name, modifiers, err := lexValue(value)
+	if err != nil {
+		return enum, err
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		enum.EnumSchema, enum.EnumName = name[:i], name[i+1:]
+	} else {
+		enum.EnumName = name
+	}
+	for _, modifier := range modifiers {
+		if modifier[0] != "values" {
+			return enum, fmt.Errorf("enum: unknown modifier %s", modifier[0])
+		}
+		enum.Values = strings.Split(modifier[1], ",")
+	}
+	if enum.EnumName == "" {
+		return enum, fmt.Errorf("enum: no name provided")
+	}
+	if len(enum.Values) == 0 {
+		return enum, fmt.Errorf("enum %s: no values provided", enum.EnumName)
+	}
+	return enum, nil
+}
+
+func enumValues(enum Enum, sep string) string {
+	values := make([]string, len(enum.Values))
+	for i, value := range enum.Values {
+		values[i] = quoteLiteral(value)
+	}
+	return strings.Join(values, sep)
+}
+
+// enumColumnType returns the column type of a column holding an enum. The
+// MySQL ENUM is spelled the way information_schema.COLUMNS.COLUMN_TYPE
+// reports it, so that it compares equal to the introspected type.
+func enumColumnType(dialect string, enum Enum) string {
+	switch dialect {
+	case "postgres":
+		return qualifiedName(dialect, enum.EnumSchema, enum.EnumName)
+	case "mysql":
+		return "enum(" + enumValues(enum, ",") + ")"
+	default:
+		return "TEXT"
+	}
+}
+
+// enumCheckConstraint returns the CHECK constraint that emulates an enum on
+// dialects without one.
+func enumCheckConstraint(tableName [2]string, columnName string, enum Enum) TableConstraint {
+	constraint := TableConstraint{
+		TableSchema:    tableName[0],
+		TableName:      tableName[1],
+		ConstraintName: tableName[1] + "_" + columnName + "_check",
+		ConstraintType: "CHECK",
+		Columns:        []string{columnName},
+	}
+	constraint.CheckExpr.String = quoteIdentifier("", columnName) + " IN (" + enumValues(enum, ", ") + ")"
+	constraint.CheckExpr.Valid = true
+	return constraint
+}
+
+func createEnumQuery(enum Enum) string {
+	return "CREATE TYPE " + qualifiedName("postgres", enum.EnumSchema, enum.EnumName) + " AS ENUM (" + enumValues(enum, ", ") + ")"
+}
+
+// AlterEnumQueries returns the ALTER TYPE ... ADD VALUE queries that evolve
+// gotEnum into wantEnum, positioning each new value with BEFORE or AFTER.
+// Postgres cannot remove or reorder the values of an enum, so that is
+// reported as an error. Before Postgres 12, ADD VALUE cannot be run inside a
+// transaction.
+func AlterEnumQueries(gotEnum, wantEnum Enum) (querylist []string, err error) {
+	gotPositions := make(map[string]int)
+	for i, value := range gotEnum.Values {
+		gotPositions[value] = i
+	}
+	var lastPosition = -1
+	for _, value := range wantEnum.Values {
+		position, ok := gotPositions[value]
+		if !ok {
+			continue
+		}
+		if position < lastPosition {
+			return nil, fmt.Errorf("enum %s: cannot reorder existing value %s", wantEnum.EnumName, value)
+		}
+		lastPosition = position
+		delete(gotPositions, value)
+	}
+	for _, value := range gotEnum.Values {
+		if _, ok := gotPositions[value]; ok {
+			return nil, fmt.Errorf("enum %s: cannot remove existing value %s", wantEnum.EnumName, value)
+		}
+	}
+	existing := make(map[string]bool)
+	for _, value := range gotEnum.Values {
+		existing[value] = true
+	}
+	typeName := qualifiedName("postgres", wantEnum.EnumSchema, wantEnum.EnumName)
+	for i, value := range wantEnum.Values {
+		if existing[value] {
+			continue
+		}
+		query := "ALTER TYPE " + typeName + " ADD VALUE " + quoteLiteral(value)
+		if i > 0 {
+			query += " AFTER " + quoteLiteral(wantEnum.Values[i-1])
+		} else {
+			for _, next := range wantEnum.Values[1:] {
+				if existing[next] {
+					query += " BEFORE " + quoteLiteral(next)
+					break
+				}
+			}
+		}
+		querylist = append(querylist, query)
+		existing[value] = true
+	}
+	return querylist, nil
+}
+
+// GetEnums returns the enum types in a Postgres database, with their values
+// in sort order.
+func GetEnums(db DB) (enums map[[2]string]Enum, err error) {
+	rows, err := db.Query(`SELECT n.nspname, t.typname, e.enumlabel
+FROM pg_enum AS e
+JOIN pg_type AS t ON t.oid = e.enumtypid
+JOIN pg_namespace AS n ON n.oid = t.typnamespace
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+ORDER BY n.nspname, t.typname, e.enumsortorder`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	enums = make(map[[2]string]Enum)
+	for rows.Next() {
+		var schema, name, value string
+		err = rows.Scan(&schema, &name, &value)
+		if err != nil {
+			return nil, err
+		}
+		enum := enums[[2]string{schema, name}]
+		enum.EnumSchema, enum.EnumName = schema, name
+		enum.Values = append(enum.Values, value)
+		enums[[2]string{schema, name}] = enum
+	}
+	return enums, rows.Err()
+}
+
+// EnsureEnums creates the enums that do not exist yet and adds any missing
+// values to the ones that do. It must be called before the tables that use
+// the enums are created (EnsureTables does so for an EnumGetter), and does
+// nothing outside of Postgres.
+func EnsureEnums(db DB, dialect string, enums []Enum) error {
+	if dialect != "postgres" {
+		return nil
+	}
+	gotEnums, err := GetEnums(db)
+	if err != nil {
+		return err
+	}
+	defaultSchema, err := DefaultSchema(db, dialect)
+	if err != nil {
+		return err
+	}
+	for _, enum := range enums {
+		schema := enum.EnumSchema
+		if schema == "" {
+			schema = defaultSchema
+		}
+		var querylist []string
+		if gotEnum, ok := gotEnums[[2]string{schema, enum.EnumName}]; ok {
+			querylist, err = AlterEnumQueries(gotEnum, enum)
+			if err != nil {
+				return err
+			}
+		} else {
+			querylist = []string{createEnumQuery(enum)}
+		}
+		for _, query := range querylist {
+			_, err = db.Exec(query)
+			if err != nil {
+				return fmt.Errorf("%s: %w", query, err)
+			}
+		}
+	}
+	return nil
+}
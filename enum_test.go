@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestEnum(t *testing.T) {
+	is := testutil.New(t)
+	mpaaRating, err := enumFromModifier("mpaa_rating values=G,PG,PG-13,R,NC-17")
+	is.NoErr(err)
+	is.Equal(Enum{EnumName: "mpaa_rating", Values: []string{"G", "PG", "PG-13", "R", "NC-17"}}, mpaaRating)
+	is.Equal("CREATE TYPE mpaa_rating AS ENUM ('G', 'PG', 'PG-13', 'R', 'NC-17')", createEnumQuery(mpaaRating))
+	is.Equal("mpaa_rating", enumColumnType("postgres", mpaaRating))
+	is.Equal("enum('G','PG','PG-13','R','NC-17')", enumColumnType("mysql", mpaaRating))
+	is.Equal("TEXT", enumColumnType("sqlite3", mpaaRating))
+
+	check := enumCheckConstraint([2]string{"", "film"}, "rating", mpaaRating)
+	is.Equal("film_rating_check", check.ConstraintName)
+	definition, err := constraintDefinition("sqlite3", check)
+	is.NoErr(err)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE film (rating " + enumColumnType("sqlite3", mpaaRating) + ", " + definition + ")")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO film (rating) VALUES ('PG-13')")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO film (rating) VALUES ('X')")
+	is.True(err != nil)
+}
+
+func TestAlterEnumQueries(t *testing.T) {
+	is := testutil.New(t)
+	gotEnum := Enum{EnumName: "mpaa_rating", Values: []string{"PG", "R"}}
+	querylist, err := AlterEnumQueries(gotEnum, Enum{EnumName: "mpaa_rating", Values: []string{"G", "PG", "PG-13", "R", "NC-17"}})
+	is.NoErr(err)
+	is.Equal([]string{
+		"ALTER TYPE mpaa_rating ADD VALUE 'G' BEFORE 'PG'",
+		"ALTER TYPE mpaa_rating ADD VALUE 'PG-13' AFTER 'PG'",
+		"ALTER TYPE mpaa_rating ADD VALUE 'NC-17' AFTER 'R'",
+	}, querylist)
+	_, err = AlterEnumQueries(gotEnum, Enum{EnumName: "mpaa_rating", Values: []string{"R", "PG"}})
+	is.True(err != nil)
+	_, err = AlterEnumQueries(gotEnum, Enum{EnumName: "mpaa_rating", Values: []string{"PG"}})
+	is.True(err != nil)
+}
@@ -0,0 +1,195 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FTS5 is an SQLite FTS5 virtual table. If Content is set to an empty string
+// the table is contentless, and if it is set to a table name the table is an
+// external content table indexing that table, kept in sync by triggers. On
+// MySQL the same table is an ordinary table with a FULLTEXT index instead.
+type FTS5 struct {
+	TableSchema  string
+	TableName    string
+	Columns      []string
+	Content      sql.NullString
+	ContentRowid string
+	Tokenize     string
+	Prefix       string
+}
+
+// fts5FromModifier parses the value of an fts5 table tag modifier such as
+// {content='film' content_rowid='film_id' tokenize={'porter unicode61'}}.
+func fts5FromModifier(tableName [2]string, columns []string, value string) (FTS5, error) {
+	fts := FTS5{TableSchema: tableName[0], TableName: tableName[1], Columns: columns}
+	modifiers, err := lexModifiers(value)
+	if err != nil {
+		return fts, err
+	}
+	for _, modifier := range modifiers {
+		optValue := strings.Trim(modifier[1], "'")
+		switch modifier[0] {
+		case "content":
+			fts.Content = sql.NullString{String: optValue, Valid: true}
+		case "content_rowid":
+			fts.ContentRowid = optValue
+		case "tokenize":
+			fts.Tokenize = optValue
+		case "prefix":
+			fts.Prefix = optValue
+		default:
+			return fts, fmt.Errorf("fts5: unknown option %s", modifier[0])
+		}
+	}
+	if len(fts.Columns) == 0 {
+		return fts, fmt.Errorf("fts5 table %s has no columns", fts.TableName)
+	}
+	return fts, nil
+}
+
+func createFTS5Query(fts FTS5) string {
+	const dialect = "sqlite3"
+	args := make([]string, len(fts.Columns))
+	for i, column := range fts.Columns {
+		args[i] = quoteIdentifier(dialect, column)
+	}
+	if fts.Content.Valid {
+		args = append(args, "content="+quoteLiteral(fts.Content.String))
+	}
+	if fts.ContentRowid != "" {
+		args = append(args, "content_rowid="+quoteLiteral(fts.ContentRowid))
+	}
+	if fts.Tokenize != "" {
+		args = append(args, "tokenize="+quoteLiteral(fts.Tokenize))
+	}
+	if fts.Prefix != "" {
+		args = append(args, "prefix="+quoteLiteral(fts.Prefix))
+	}
+	return "CREATE VIRTUAL TABLE " + qualifiedName(dialect, fts.TableSchema, fts.TableName) + " USING fts5(" + strings.Join(args, ", ") + ")"
+}
+
+// fts5SyncTriggers returns the triggers on the content table that keep an
+// external content FTS5 table in sync with it.
+// https://www.sqlite.org/fts5.html#external_content_tables
+func fts5SyncTriggers(fts FTS5) []Trigger {
+	const dialect = "sqlite3"
+	if !fts.Content.Valid || fts.Content.String == "" {
+		return nil
+	}
+	rowid := fts.ContentRowid
+	if rowid == "" {
+		rowid = "rowid"
+	}
+	table := quoteIdentifier(dialect, fts.TableName)
+	columns := make([]string, len(fts.Columns))
+	newValues := make([]string, len(fts.Columns))
+	oldValues := make([]string, len(fts.Columns))
+	for i, column := range fts.Columns {
+		columns[i] = quoteIdentifier(dialect, column)
+		newValues[i] = "NEW." + columns[i]
+		oldValues[i] = "OLD." + columns[i]
+	}
+	insertNew := "INSERT INTO " + table + " (rowid, " + strings.Join(columns, ", ") + ")" +
+		" VALUES (NEW." + quoteIdentifier(dialect, rowid) + ", " + strings.Join(newValues, ", ") + ");"
+	deleteOld := "INSERT INTO " + table + " (" + table + ", rowid, " + strings.Join(columns, ", ") + ")" +
+		" VALUES ('delete', OLD." + quoteIdentifier(dialect, rowid) + ", " + strings.Join(oldValues, ", ") + ");"
+	trigger := func(event, body string) Trigger {
+		return Trigger{
+			TableSchema: fts.TableSchema,
+			TableName:   fts.Content.String,
+			TriggerName: fts.TableName + "_sync_after_" + strings.ToLower(event) + "_trg",
+			Timing:      "AFTER",
+			Events:      []string{event},
+			Body:        "BEGIN " + body + " END",
+		}
+	}
+	return []Trigger{
+		trigger("INSERT", insertNew),
+		trigger("DELETE", deleteOld),
+		trigger("UPDATE", deleteOld+" "+insertNew),
+	}
+}
+
+// fts5FulltextIndex returns the FULLTEXT index that stands in for an FTS5
+// table on MySQL.
+func fts5FulltextIndex(fts FTS5) Index {
+	return Index{
+		TableSchema: fts.TableSchema,
+		TableName:   fts.TableName,
+		IndexName:   fts.TableName + "_" + strings.Join(fts.Columns, "_") + "_idx",
+		IndexType:   "FULLTEXT",
+		Columns:     fts.Columns,
+	}
+}
+
+var sqliteVirtualTableRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+VIRTUAL\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?\S+\s+USING\s+fts5\s*\(`)
+
+// GetFTS5Tables returns the CREATE VIRTUAL TABLE statements of the FTS5
+// tables in an SQLite database.
+func GetFTS5Tables(db DB) (definitions map[string]string, err error) {
+	rows, err := db.Query("SELECT name, sql FROM sqlite_master WHERE type = 'table' AND sql LIKE 'CREATE VIRTUAL TABLE%'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	definitions = make(map[string]string)
+	for rows.Next() {
+		var name, definition string
+		err = rows.Scan(&name, &definition)
+		if err != nil {
+			return nil, err
+		}
+		if sqliteVirtualTableRegexp.MatchString(definition) {
+			definitions[name] = definition
+		}
+	}
+	return definitions, rows.Err()
+}
+
+// EnsureFTS5 creates an FTS5 table, or drops and recreates it if its
+// definition differs, along with the sync triggers of an external content
+// table. A recreated external content table is rebuilt from its content
+// table. Any other FTS5 table holds data that cannot be rebuilt, so if it
+// differs and is not empty an error is returned instead.
+func EnsureFTS5(db DB, fts FTS5) error {
+	const dialect = "sqlite3"
+	definitions, err := GetFTS5Tables(db)
+	if err != nil {
+		return err
+	}
+	var querylist []string
+	table := qualifiedName(dialect, fts.TableSchema, fts.TableName)
+	isExternalContent := fts.Content.Valid && fts.Content.String != ""
+	createQuery := createFTS5Query(fts)
+	definition, ok := definitions[fts.TableName]
+	if !ok || normalizeExpr(definition) != normalizeExpr(createQuery) {
+		if ok && !isExternalContent {
+			var hasRows bool
+			query := "SELECT EXISTS (SELECT 1 FROM " + table + ")"
+			err = db.QueryRow(query).Scan(&hasRows)
+			if err != nil {
+				return fmt.Errorf("%s: %w", query, err)
+			}
+			if hasRows {
+				return fmt.Errorf("fts5 table %s: definition differs but the table holds its own rows, which recreating it would lose", fts.TableName)
+			}
+		}
+		if ok {
+			querylist = append(querylist, "DROP TABLE "+table)
+		}
+		querylist = append(querylist, createQuery)
+		if isExternalContent {
+			querylist = append(querylist, "INSERT INTO "+table+" ("+quoteIdentifier(dialect, fts.TableName)+") VALUES ('rebuild')")
+		}
+	}
+	for _, query := range querylist {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return EnsureTriggers(db, dialect, fts5SyncTriggers(fts))
+}
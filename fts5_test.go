@@ -0,0 +1,65 @@
+package metadata
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestFTS5(t *testing.T) {
+	is := testutil.New(t)
+	filmText := [2]string{"", "film_text"}
+	fts, err := fts5FromModifier(filmText, []string{"title", "description"}, "content='film' content_rowid='film_id'")
+	is.NoErr(err)
+	is.Equal("CREATE VIRTUAL TABLE film_text USING fts5(title, description, content='film', content_rowid='film_id')", createFTS5Query(fts))
+
+	triggers := fts5SyncTriggers(fts)
+	is.Equal(3, len(triggers))
+	is.Equal("film", triggers[0].TableName)
+	is.Equal("BEGIN INSERT INTO film_text (rowid, title, description) VALUES (NEW.film_id, NEW.title, NEW.description); END", triggers[0].Body)
+	is.Equal("BEGIN INSERT INTO film_text (film_text, rowid, title, description) VALUES ('delete', OLD.film_id, OLD.title, OLD.description); END", triggers[1].Body)
+
+	contentless, err := fts5FromModifier(filmText, []string{"title"}, "content='' tokenize={'porter unicode61'}")
+	is.NoErr(err)
+	is.Equal("CREATE VIRTUAL TABLE film_text USING fts5(title, content='', tokenize='porter unicode61')", createFTS5Query(contentless))
+	is.Equal(0, len(fts5SyncTriggers(contentless)))
+
+	query, err := createIndexQuery("mysql", fts5FulltextIndex(fts))
+	is.NoErr(err)
+	is.Equal("CREATE FULLTEXT INDEX film_text_title_description_idx ON film_text (title, description)", query)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE VIRTUAL TABLE fts5_probe USING fts5(x)")
+	if err != nil && strings.Contains(err.Error(), "no such module") {
+		t.Skip("sqlite3 was built without FTS5, build with -tags sqlite_fts5")
+	}
+	is.NoErr(err)
+	_, err = db.Exec("CREATE TABLE film (film_id INTEGER PRIMARY KEY, title TEXT, description TEXT)")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO film (title, description) VALUES ('ACADEMY DINOSAUR', 'An Epic Drama')")
+	is.NoErr(err)
+	is.NoErr(EnsureFTS5(db, fts))
+	is.NoErr(EnsureFTS5(db, fts))
+	_, err = db.Exec("INSERT INTO film (title, description) VALUES ('ACE GOLDFINGER', 'A Astounding Epistle')")
+	is.NoErr(err)
+	var count int
+	is.NoErr(db.QueryRow("SELECT COUNT(*) FROM film_text WHERE film_text MATCH 'epic OR epistle'").Scan(&count))
+	is.Equal(2, count)
+
+	notes := FTS5{TableName: "note_text", Columns: []string{"body"}}
+	is.NoErr(EnsureFTS5(db, notes))
+	_, err = db.Exec("INSERT INTO note_text (body) VALUES ('An Epic Drama')")
+	is.NoErr(err)
+	notes.Tokenize = "porter unicode61"
+	is.True(EnsureFTS5(db, notes) != nil)
+	is.NoErr(db.QueryRow("SELECT COUNT(*) FROM note_text WHERE note_text MATCH 'epic'").Scan(&count))
+	is.Equal(1, count)
+	_, err = db.Exec("DELETE FROM note_text")
+	is.NoErr(err)
+	is.NoErr(EnsureFTS5(db, notes))
+}
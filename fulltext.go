@@ -0,0 +1,84 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// FullText describes a Postgres full-text search column: a TSVECTOR column
+// generated from the text of Columns using the Config text search
+// configuration, and indexed by a GIN (the default) or GIST index.
+type FullText struct {
+	Config    string
+	Columns   []string
+	IndexType string // GIN | GIST
+}
+
+// fullTextFromModifier parses the value of a fulltext tag modifier such as
+// {english cols=title,description index=gist}.
+func fullTextFromModifier(value string) (FullText, error) {
+	var fullText FullText
+	config, modifiers, err := lexValue(value)
+	if err != nil {
+		return fullText, err
+	}
+	fullText.Config = config
+	for _, modifier := range modifiers {
+		switch modifier[0] {
+		case "cols":
+			fullText.Columns = strings.Split(modifier[1], ",")
+		case "index":
+			fullText.IndexType = strings.ToUpper(modifier[1])
+		default:
+			return fullText, fmt.Errorf("fulltext: unknown modifier %s", modifier[0])
+		}
+	}
+	if fullText.Config == "" {
+		return fullText, fmt.Errorf("fulltext: no text search configuration provided")
+	}
+	if len(fullText.Columns) == 0 {
+		return fullText, fmt.Errorf("fulltext: no cols provided")
+	}
+	return fullText, nil
+}
+
+// fullTextColumn returns the generated TSVECTOR column and its index for a
+// full-text search column. Since they are an ordinary Column and Index, a
+// change in the configuration or the source columns shows up as a
+// "generated" mismatch like any other generated column.
+func fullTextColumn(tableName [2]string, columnName string, fullText FullText) (Column, Index, error) {
+	const dialect = "postgres"
+	switch fullText.IndexType {
+	case "", "GIN", "GIST":
+	default:
+		return Column{}, Index{}, fmt.Errorf("fulltext: index type must be GIN or GIST, not %s", fullText.IndexType)
+	}
+	values := make([]string, len(fullText.Columns))
+	for i, column := range fullText.Columns {
+		values[i] = "COALESCE(" + quoteIdentifier(dialect, column) + ", '')"
+	}
+	column := Column{
+		TableSchema:     tableName[0],
+		TableName:       tableName[1],
+		ColumnName:      columnName,
+		ColumnType:      "TSVECTOR",
+		GeneratedStored: true,
+		GeneratedExpr: sql.NullString{
+			String: "to_tsvector(" + quoteLiteral(fullText.Config) + ", " + strings.Join(values, " || ' ' || ") + ")",
+			Valid:  true,
+		},
+	}
+	indexType := fullText.IndexType
+	if indexType == "" {
+		indexType = "GIN"
+	}
+	index := Index{
+		TableSchema: tableName[0],
+		TableName:   tableName[1],
+		IndexName:   tableName[1] + "_" + columnName + "_idx",
+		IndexType:   indexType,
+		Columns:     []string{columnName},
+	}
+	return column, index, nil
+}
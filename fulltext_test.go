@@ -0,0 +1,33 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestFullTextColumn(t *testing.T) {
+	is := testutil.New(t)
+	fullText, err := fullTextFromModifier("pg_catalog.english cols=title,description index=gist")
+	is.NoErr(err)
+	column, index, err := fullTextColumn([2]string{"", "film"}, "fulltext", fullText)
+	is.NoErr(err)
+	is.Equal("fulltext TSVECTOR GENERATED ALWAYS AS (to_tsvector('pg_catalog.english', COALESCE(title, '') || ' ' || COALESCE(description, ''))) STORED", columnDefinition("postgres", column))
+	query, err := createIndexQuery("postgres", index)
+	is.NoErr(err)
+	is.Equal("CREATE INDEX film_fulltext_idx ON film USING GIST (fulltext)", query)
+
+	gotColumn := column
+	gotColumn.ColumnType = "tsvector"
+	gotColumn.GeneratedExpr = sql.NullString{
+		String: "to_tsvector('pg_catalog.english'::regconfig, ((COALESCE(title, ''::text) || ' '::text) || COALESCE(description, ''::text)))",
+		Valid:  true,
+	}
+	is.Equal(0, len(ColumnMismatches("postgres", gotColumn, column)))
+	gotColumn.GeneratedExpr.String = "to_tsvector('pg_catalog.simple'::regconfig, COALESCE(title, ''::text))"
+	is.Equal([]string{"generated"}, ColumnMismatches("postgres", gotColumn, column))
+
+	_, _, err = fullTextColumn([2]string{"", "film"}, "fulltext", FullText{Config: "english", Columns: []string{"title"}, IndexType: "BRIN"})
+	is.True(err != nil)
+}
@@ -0,0 +1,616 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// createIndexQuery returns the CREATE INDEX query for an index. A key part
+// that has no column name is taken from Exprs at the same position.
+func createIndexQuery(dialect string, index Index) (string, error) {
+	if index.IndexName == "" {
+		return "", fmt.Errorf("index on table %s has no name", index.TableName)
+	}
+	err := ValidateIndex(dialect, index)
+	if err != nil {
+		return "", err
+	}
+	keyParts := make([]string, len(index.Columns))
+	for i := range index.Columns {
+		keyPart, err := keyPartDefinition(dialect, index, i)
+		if err != nil {
+			return "", err
+		}
+		keyParts[i] = keyPart
+	}
+	if len(keyParts) == 0 {
+		return "", fmt.Errorf("index %s has no columns", index.IndexName)
+	}
+	indexType, _ := dialectIndexType(dialect, index.IndexType)
+	buf := &strings.Builder{}
+	buf.WriteString("CREATE ")
+	if index.IsUnique {
+		buf.WriteString("UNIQUE ")
+	}
+	if dialect == "mysql" && (indexType == "FULLTEXT" || indexType == "SPATIAL") {
+		buf.WriteString(indexType + " ")
+	}
+	buf.WriteString("INDEX ")
+	if index.Online && dialect == "postgres" {
+		buf.WriteString("CONCURRENTLY ")
+	}
+	buf.WriteString(quoteIdentifier(dialect, index.IndexName))
+	buf.WriteString(" ON " + qualifiedName(dialect, index.TableSchema, index.TableName))
+	if dialect == "postgres" && indexType != "" {
+		buf.WriteString(" USING " + indexType)
+	}
+	buf.WriteString(" (" + strings.Join(keyParts, ", ") + ")")
+	if dialect == "mysql" && (indexType == "BTREE" || indexType == "HASH") {
+		buf.WriteString(" USING " + indexType)
+	}
+	if len(index.Include) > 0 {
+		buf.WriteString(" INCLUDE (" + quoteIdentifiers(dialect, index.Include) + ")")
+	}
+	if index.Where != "" {
+		buf.WriteString(" WHERE " + index.Where)
+	}
+	if index.Online && dialect == "mysql" {
+		buf.WriteString(" ALGORITHM=INPLACE LOCK=NONE")
+	}
+	return buf.String(), nil
+}
+
+// indexTypes lists the index types each dialect supports. SPATIAL is accepted
+// on Postgres as GIST, the access method PostGIS builds spatial indexes with.
+var indexTypes = map[string]map[string]string{
+	"postgres": {"BTREE": "BTREE", "HASH": "HASH", "GIST": "GIST", "SPGIST": "SPGIST", "GIN": "GIN", "BRIN": "BRIN", "SPATIAL": "GIST"},
+	"mysql":    {"BTREE": "BTREE", "HASH": "HASH", "FULLTEXT": "FULLTEXT", "SPATIAL": "SPATIAL"},
+	"sqlite3":  {"BTREE": ""},
+}
+
+// dialectIndexType validates an index type (in any case, and with SP-GIST
+// spelled with or without the dash) against a dialect and returns the name
+// the dialect knows it by.
+func dialectIndexType(dialect string, indexType string) (string, error) {
+	if indexType == "" {
+		return "", nil
+	}
+	name := strings.ToUpper(strings.NewReplacer("-", "", "_", "").Replace(indexType))
+	dialectType, ok := indexTypes[dialect][name]
+	if !ok {
+		return "", fmt.Errorf("%s does not support %s indexes", dialect, indexType)
+	}
+	return dialectType, nil
+}
+
+// ValidateIndex checks that a dialect supports an index's type and options,
+// so that they are reported when the index is declared rather than when it
+// is created.
+func ValidateIndex(dialect string, index Index) error {
+	_, err := dialectIndexType(dialect, index.IndexType)
+	if err != nil {
+		return fmt.Errorf("index %s: %w", index.IndexName, err)
+	}
+	if dialect != "postgres" && len(index.Include) > 0 {
+		return fmt.Errorf("%s: index %s: INCLUDE columns are only supported by postgres", dialect, index.IndexName)
+	}
+	if dialect == "mysql" && index.Where != "" {
+		return fmt.Errorf("mysql: index %s: partial indexes are not supported", index.IndexName)
+	}
+	for i := range index.Columns {
+		if keyPartPrefixLength(index, i) > 0 && dialect != "mysql" {
+			return fmt.Errorf("%s: index %s: prefix lengths are only supported by mysql", dialect, index.IndexName)
+		}
+		if keyPartOption(index.Collations, i) != "" && dialect == "mysql" {
+			return fmt.Errorf("mysql: index %s: key part collations are not supported", index.IndexName)
+		}
+		if keyPartOption(index.Opclasses, i) != "" && dialect != "postgres" {
+			return fmt.Errorf("%s: index %s: operator classes are only supported by postgres", dialect, index.IndexName)
+		}
+		if keyPartOption(index.NullsOrders, i) != "" && dialect != "postgres" {
+			return fmt.Errorf("%s: index %s: NULLS FIRST/LAST is only supported by postgres", dialect, index.IndexName)
+		}
+	}
+	return nil
+}
+
+// indexFromFields returns the index declared by c.Index, c.UniqueIndex or
+// c.IndexInclude on a table, validated against the dialect. An index that is
+// not given a name is named after its table and columns.
+func indexFromFields(dialect string, tableName [2]string, idxSchema, idxName, idxType string, isUnique bool, fields []Field, include []Field) (Index, error) {
+	index := Index{
+		TableSchema: tableName[0],
+		TableName:   tableName[1],
+		IndexSchema: idxSchema,
+		IndexName:   idxName,
+		IndexType:   strings.ToUpper(idxType),
+		IsUnique:    isUnique,
+	}
+	for _, field := range fields {
+		if field == nil {
+			return index, fmt.Errorf("index %s: nil field", idxName)
+		}
+		index.Columns = append(index.Columns, field.GetName())
+		index.Exprs = append(index.Exprs, "")
+	}
+	for _, field := range include {
+		if field == nil {
+			return index, fmt.Errorf("index %s: nil include field", idxName)
+		}
+		index.Include = append(index.Include, field.GetName())
+	}
+	if index.IndexName == "" {
+		index.IndexName = index.TableName + "_" + strings.Join(index.Columns, "_") + "_idx"
+	}
+	return index, ValidateIndex(dialect, index)
+}
+
+// keyPartDefinition returns the i-th key part of an index together with its
+// collation, operator class, sort order and prefix length.
+func keyPartDefinition(dialect string, index Index, i int) (string, error) {
+	buf := &strings.Builder{}
+	if column := index.Columns[i]; column != "" {
+		buf.WriteString(quoteIdentifier(dialect, column))
+	} else if expr := indexExpr(index, i); expr != "" {
+		buf.WriteString("(" + expr + ")")
+	} else {
+		return "", fmt.Errorf("index %s: key part %d has neither a column nor an expression", index.IndexName, i+1)
+	}
+	if prefixLength := keyPartPrefixLength(index, i); prefixLength > 0 {
+		fmt.Fprintf(buf, "(%d)", prefixLength)
+	}
+	if collation := keyPartOption(index.Collations, i); collation != "" {
+		buf.WriteString(" " + collationClause(dialect, collation))
+	}
+	if opclass := keyPartOption(index.Opclasses, i); opclass != "" {
+		buf.WriteString(" " + opclass)
+	}
+	if direction := keyPartOption(index.Directions, i); direction != "" {
+		buf.WriteString(" " + strings.ToUpper(direction))
+	}
+	if nullsOrder := keyPartOption(index.NullsOrders, i); nullsOrder != "" {
+		buf.WriteString(" " + strings.ToUpper(nullsOrder))
+	}
+	return buf.String(), nil
+}
+
+func keyPartOption(options []string, i int) string {
+	if i < len(options) {
+		return options[i]
+	}
+	return ""
+}
+
+func keyPartPrefixLength(index Index, i int) int {
+	if i < len(index.PrefixLengths) {
+		return index.PrefixLengths[i]
+	}
+	return 0
+}
+
+// keyPartOrder returns the sort order and NULLS ordering of the i-th key part
+// with the defaults spelled out: ASC, and NULLS LAST for ASC or NULLS FIRST
+// for DESC.
+func keyPartOrder(index Index, i int) (direction, nullsOrder string) {
+	direction = strings.ToUpper(keyPartOption(index.Directions, i))
+	if direction == "" {
+		direction = "ASC"
+	}
+	nullsOrder = strings.ToUpper(keyPartOption(index.NullsOrders, i))
+	if nullsOrder == "" {
+		if direction == "DESC" {
+			nullsOrder = "NULLS FIRST"
+		} else {
+			nullsOrder = "NULLS LAST"
+		}
+	}
+	return direction, nullsOrder
+}
+
+// EnsureIndex creates an index. For an Online index on Postgres, db must not
+// be a transaction because CREATE INDEX CONCURRENTLY cannot run inside one. A
+// failed concurrent build leaves behind an INVALID index, which is dropped
+// both before retrying and after failing.
+func EnsureIndex(db DB, dialect string, index Index) error {
+	query, err := createIndexQuery(dialect, index)
+	if err != nil {
+		return err
+	}
+	if dialect != "postgres" {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+		return nil
+	}
+	err = dropInvalidIndex(db, index)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(query)
+	if err != nil {
+		if cleanupErr := dropInvalidIndex(db, index); cleanupErr != nil {
+			return fmt.Errorf("%s: %w (cleanup failed: %s)", query, err, cleanupErr.Error())
+		}
+		return fmt.Errorf("%s: %w", query, err)
+	}
+	return nil
+}
+
+// isInvalidIndex reports whether a Postgres index exists but is INVALID.
+func isInvalidIndex(db DB, index Index) (bool, error) {
+	var isInvalid bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_index JOIN pg_class ON pg_class.oid = pg_index.indexrelid"+
+		" JOIN pg_namespace ON pg_namespace.oid = pg_class.relnamespace"+
+		" WHERE pg_namespace.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND pg_class.relname = $2 AND NOT pg_index.indisvalid)",
+		index.TableSchema, index.IndexName,
+	).Scan(&isInvalid)
+	return isInvalid, err
+}
+
+func dropInvalidIndex(db DB, index Index) error {
+	const dialect = "postgres"
+	isInvalid, err := isInvalidIndex(db, index)
+	if err != nil || !isInvalid {
+		return err
+	}
+	query := "DROP INDEX IF EXISTS " + qualifiedName(dialect, index.TableSchema, index.IndexName)
+	if index.Online {
+		query = "DROP INDEX CONCURRENTLY IF EXISTS " + qualifiedName(dialect, index.TableSchema, index.IndexName)
+	}
+	_, err = db.Exec(query)
+	return err
+}
+
+// indexChanged reports whether an index in the database differs from the
+// index that was declared. Expressions and WHERE predicates are compared
+// loosely with normalizeExpr, since databases report them back rewritten.
+func indexChanged(dialect string, got, want Index) bool {
+	if got.IsUnique != want.IsUnique {
+		return true
+	}
+	if indexTypeOrDefault(dialect, got.IndexType) != indexTypeOrDefault(dialect, want.IndexType) {
+		return true
+	}
+	if len(got.Columns) != len(want.Columns) {
+		return true
+	}
+	for i := range want.Columns {
+		if want.Columns[i] != "" || got.Columns[i] != "" {
+			if !strings.EqualFold(got.Columns[i], want.Columns[i]) {
+				return true
+			}
+			continue
+		}
+		if normalizeExpr(indexExpr(got, i)) != normalizeExpr(indexExpr(want, i)) {
+			return true
+		}
+	}
+	for i := range want.Columns {
+		gotDirection, gotNullsOrder := keyPartOrder(got, i)
+		wantDirection, wantNullsOrder := keyPartOrder(want, i)
+		if gotDirection != wantDirection || gotNullsOrder != wantNullsOrder {
+			return true
+		}
+		if !strings.EqualFold(keyPartOption(got.Opclasses, i), keyPartOption(want.Opclasses, i)) {
+			return true
+		}
+		if !strings.EqualFold(strings.Trim(keyPartOption(got.Collations, i), `"`), strings.Trim(keyPartOption(want.Collations, i), `"`)) {
+			return true
+		}
+		if keyPartPrefixLength(got, i) != keyPartPrefixLength(want, i) {
+			return true
+		}
+	}
+	if !sameNames(got.Include, want.Include) {
+		return true
+	}
+	return normalizeExpr(got.Where) != normalizeExpr(want.Where)
+}
+
+// sameNames reports whether two lists hold the same identifiers, ignoring
+// order and case.
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, name := range a {
+		counts[strings.ToLower(name)]++
+	}
+	for _, name := range b {
+		counts[strings.ToLower(name)]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// indexTypeOrDefault treats an unspecified index type as BTREE, the default
+// on every dialect (and what MySQL reports for an index declared without
+// one). InnoDB builds a BTREE for USING HASH and reports it as such, so HASH
+// is treated as BTREE on MySQL.
+func indexTypeOrDefault(dialect string, indexType string) string {
+	switch name := strings.ToUpper(strings.NewReplacer("-", "", "_", "").Replace(indexType)); name {
+	case "":
+		return "BTREE"
+	case "HASH":
+		if dialect == "mysql" {
+			return "BTREE"
+		}
+		return name
+	case "SPATIAL":
+		return "GIST"
+	default:
+		return name
+	}
+}
+
+func indexExpr(index Index, i int) string {
+	if i < len(index.Exprs) {
+		return index.Exprs[i]
+	}
+	return ""
+}
+
+// GetIndexes returns the indexes of a table in the database, excluding the
+// ones backing its primary key. Expression key parts have an empty column
+// name and their expression text in Exprs.
+func GetIndexes(db DB, dialect string, tableName [2]string) (map[string]Index, error) {
+	switch dialect {
+	case "postgres":
+		return getPostgresIndexes(db, tableName)
+	case "mysql":
+		return getMySQLIndexes(db, tableName)
+	case "sqlite3":
+		return getSQLiteIndexes(db, tableName)
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+func getPostgresIndexes(db DB, tableName [2]string) (map[string]Index, error) {
+	const query = `SELECT i.relname, am.amname, x.indisunique, COALESCE(pg_get_expr(x.indpred, x.indrelid, true), '')
+	,COALESCE(a.attname, ''), CASE WHEN k.attnum = 0 THEN pg_get_indexdef(x.indexrelid, k.ord::INT, true) ELSE '' END
+	,k.ord > x.indnkeyatts, COALESCE(x.indoption[k.ord - 1], 0), CASE WHEN opc.opcdefault IS NOT FALSE THEN '' ELSE opc.opcname END
+	,CASE WHEN coll.collname IS NULL OR coll.collname = 'default' OR coll.oid = a.attcollation THEN '' ELSE coll.collname END
+FROM pg_index AS x
+JOIN pg_class AS i ON i.oid = x.indexrelid
+JOIN pg_class AS t ON t.oid = x.indrelid
+JOIN pg_namespace AS tn ON tn.oid = t.relnamespace
+JOIN pg_am AS am ON am.oid = i.relam
+CROSS JOIN LATERAL unnest(x.indkey::INT2[]) WITH ORDINALITY AS k(attnum, ord)
+LEFT JOIN pg_attribute AS a ON a.attrelid = x.indrelid AND a.attnum = k.attnum AND k.attnum > 0
+LEFT JOIN pg_opclass AS opc ON opc.oid = x.indclass[k.ord - 1]
+LEFT JOIN pg_collation AS coll ON coll.oid = x.indcollation[k.ord - 1]
+WHERE NOT x.indisprimary AND tn.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND t.relname = $2
+ORDER BY i.relname, k.ord`
+	rows, err := db.Query(query, tableName[0], tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make(map[string]Index)
+	for rows.Next() {
+		var name, indexType, where, column, expr, opclass, collation string
+		var isUnique, isInclude bool
+		var indoption int
+		err = rows.Scan(&name, &indexType, &isUnique, &where, &column, &expr, &isInclude, &indoption, &opclass, &collation)
+		if err != nil {
+			return nil, err
+		}
+		index, ok := indexes[name]
+		if !ok {
+			index = Index{
+				TableSchema: tableName[0],
+				TableName:   tableName[1],
+				IndexName:   name,
+				IndexType:   strings.ToUpper(indexType),
+				IsUnique:    isUnique,
+				IsPartial:   where != "",
+				Where:       where,
+			}
+		}
+		// Columns after the first indnkeyatts are INCLUDE columns, not keys.
+		if isInclude {
+			index.Include = append(index.Include, column)
+			indexes[name] = index
+			continue
+		}
+		// indoption bit 0 is DESC and bit 1 is NULLS FIRST.
+		direction, nullsOrder := "ASC", "NULLS LAST"
+		if indoption&1 != 0 {
+			direction = "DESC"
+		}
+		if indoption&2 != 0 {
+			nullsOrder = "NULLS FIRST"
+		}
+		index.Columns = append(index.Columns, column)
+		index.Exprs = append(index.Exprs, expr)
+		index.Directions = append(index.Directions, direction)
+		index.NullsOrders = append(index.NullsOrders, nullsOrder)
+		index.Opclasses = append(index.Opclasses, opclass)
+		index.Collations = append(index.Collations, collation)
+		indexes[name] = index
+	}
+	return indexes, rows.Err()
+}
+
+func getMySQLIndexes(db DB, tableName [2]string) (map[string]Index, error) {
+	const query = `SELECT INDEX_NAME, INDEX_TYPE, NON_UNIQUE = 0, COALESCE(COLUMN_NAME, ''), COALESCE(EXPRESSION, '')
+	,COALESCE(COLLATION, ''), COALESCE(SUB_PART, 0)
+FROM information_schema.STATISTICS
+WHERE INDEX_NAME <> 'PRIMARY' AND TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?
+ORDER BY INDEX_NAME, SEQ_IN_INDEX`
+	rows, err := db.Query(query, tableName[0], tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make(map[string]Index)
+	for rows.Next() {
+		var name, indexType, column, expr, collation string
+		var isUnique bool
+		var prefixLength int
+		err = rows.Scan(&name, &indexType, &isUnique, &column, &expr, &collation, &prefixLength)
+		if err != nil {
+			return nil, err
+		}
+		index, ok := indexes[name]
+		if !ok {
+			index = Index{
+				TableSchema: tableName[0],
+				TableName:   tableName[1],
+				IndexName:   name,
+				IndexType:   indexType,
+				IsUnique:    isUnique,
+			}
+		}
+		index.Columns = append(index.Columns, column)
+		index.Exprs = append(index.Exprs, expr)
+		// STATISTICS.COLLATION is the sort order of the key part: A or D.
+		if collation == "D" {
+			index.Directions = append(index.Directions, "DESC")
+		} else {
+			index.Directions = append(index.Directions, "ASC")
+		}
+		index.PrefixLengths = append(index.PrefixLengths, prefixLength)
+		indexes[name] = index
+	}
+	return indexes, rows.Err()
+}
+
+// getSQLiteIndexes reads each index's key parts and WHERE clause back out of
+// the CREATE INDEX statement SQLite keeps in sqlite_master, because
+// pragma_index_xinfo does not report the text of expressions.
+func getSQLiteIndexes(db DB, tableName [2]string) (map[string]Index, error) {
+	rows, err := db.Query("SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	indexes := make(map[string]Index)
+	for rows.Next() {
+		index := Index{TableSchema: tableName[0], TableName: tableName[1]}
+		var query string
+		err = rows.Scan(&index.IndexName, &query)
+		if err != nil {
+			return nil, err
+		}
+		err = parseSQLiteCreateIndex(&index, query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", query, err)
+		}
+		indexes[index.IndexName] = index
+	}
+	return indexes, rows.Err()
+}
+
+// parseSQLiteCreateIndex fills in the uniqueness, key parts and WHERE clause
+// of an index from its CREATE INDEX statement.
+func parseSQLiteCreateIndex(index *Index, query string) error {
+	index.IsUnique = strings.HasPrefix(strings.ToUpper(strings.TrimSpace(query)), "CREATE UNIQUE")
+	start, end, depth := -1, -1, 0
+	var quote byte
+	var keyParts []string
+	partStart := 0
+	for i := 0; i < len(query) && end < 0; i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '[':
+			quote = ']'
+		case '(':
+			depth++
+			if depth == 1 && start < 0 {
+				start, partStart = i, i+1
+			}
+		case ')':
+			depth--
+			if depth == 0 && start >= 0 {
+				end = i
+				keyParts = append(keyParts, query[partStart:i])
+			}
+		case ',':
+			if depth == 1 {
+				keyParts = append(keyParts, query[partStart:i])
+				partStart = i + 1
+			}
+		}
+	}
+	if end < 0 {
+		return fmt.Errorf("could not find the key parts of index %s", index.IndexName)
+	}
+	for _, keyPart := range keyParts {
+		keyPart, collation, direction := cutKeyPartOptions(strings.TrimSpace(keyPart))
+		index.Collations = append(index.Collations, collation)
+		index.Directions = append(index.Directions, direction)
+		if name, ok := sqliteIdentifier(keyPart); ok {
+			index.Columns = append(index.Columns, name)
+			index.Exprs = append(index.Exprs, "")
+			continue
+		}
+		if strings.HasPrefix(keyPart, "(") && strings.HasSuffix(keyPart, ")") {
+			keyPart = keyPart[1 : len(keyPart)-1]
+		}
+		index.Columns = append(index.Columns, "")
+		index.Exprs = append(index.Exprs, keyPart)
+	}
+	rest := strings.TrimSpace(query[end+1:])
+	if len(rest) >= 5 && strings.EqualFold(rest[:5], "WHERE") {
+		index.IsPartial = true
+		index.Where = strings.TrimSpace(rest[5:])
+	}
+	return nil
+}
+
+// cutKeyPartOptions cuts a trailing ASC or DESC and COLLATE clause off a key
+// part.
+func cutKeyPartOptions(keyPart string) (rest, collation, direction string) {
+	rest = keyPart
+	for _, keyword := range []string{"ASC", "DESC"} {
+		if n := len(rest) - len(keyword); n > 0 && strings.EqualFold(rest[n:], keyword) && unicode.IsSpace(rune(rest[n-1])) {
+			rest, direction = strings.TrimSpace(rest[:n]), keyword
+			break
+		}
+	}
+	if i := strings.LastIndex(strings.ToUpper(rest), " COLLATE "); i >= 0 {
+		name := strings.TrimSpace(rest[i+len(" COLLATE "):])
+		if _, ok := sqliteIdentifier(name); ok {
+			rest, collation = strings.TrimSpace(rest[:i]), name
+		}
+	}
+	return rest, collation, direction
+}
+
+// sqliteIdentifier returns the name of a plain or quoted identifier.
+func sqliteIdentifier(s string) (name string, ok bool) {
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"':
+			return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`), true
+		case s[0] == '`' && s[len(s)-1] == '`':
+			return strings.ReplaceAll(s[1:len(s)-1], "``", "`"), true
+		case s[0] == '[' && s[len(s)-1] == ']':
+			return s[1 : len(s)-1], true
+		}
+	}
+	for i, r := range s {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+			continue
+		}
+		return "", false
+	}
+	return s, s != ""
+}
@@ -0,0 +1,294 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestCreateIndexQuery(t *testing.T) {
+	assert := func(t *testing.T, dialect string, index Index, wantQuery string) {
+		is := testutil.New(t)
+		gotQuery, err := createIndexQuery(dialect, index)
+		is.NoErr(err)
+		is.Equal(wantQuery, gotQuery)
+	}
+	paymentIdx := Index{
+		TableName: "payment",
+		IndexName: "payment_customer_id_idx",
+		Columns:   []string{"customer_id"},
+		Online:    true,
+	}
+	assert(t, "postgres", paymentIdx, "CREATE INDEX CONCURRENTLY payment_customer_id_idx ON payment (customer_id)")
+	assert(t, "mysql", paymentIdx, "CREATE INDEX payment_customer_id_idx ON payment (customer_id) ALGORITHM=INPLACE LOCK=NONE")
+	assert(t, "sqlite3", paymentIdx, "CREATE INDEX payment_customer_id_idx ON payment (customer_id)")
+	assert(t, "postgres", Index{
+		TableSchema: "public",
+		TableName:   "rental",
+		IndexName:   "rental_rental_date_inventory_id_customer_id_idx",
+		IndexType:   "btree",
+		IsUnique:    true,
+		Columns:     []string{"rental_date", "inventory_id", "customer_id"},
+	}, "CREATE UNIQUE INDEX rental_rental_date_inventory_id_customer_id_idx ON public.rental USING BTREE (rental_date, inventory_id, customer_id)")
+
+	is := testutil.New(t)
+	_, err := createIndexQuery("postgres", Index{TableName: "payment", IndexName: "payment_idx", Columns: []string{""}})
+	is.True(err != nil)
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	is := testutil.New(t)
+	tableName := [2]string{"", "customer"}
+	var parts []indexPart
+	for _, modifier := range [][2]string{
+		{"data", "1 order=2 expr={CAST(SUBSTR(data, 1, 3) AS INT)}"},
+		{"store_id", "1 where={active = 1}"},
+	} {
+		part, err := indexPartFromModifier("postgres", tableName, modifier[0], modifier[1])
+		is.NoErr(err)
+		parts = append(parts, part)
+	}
+	part, err := indexPartFromModifier("postgres", tableName, "", ". cols=last_name,first_name unique")
+	is.NoErr(err)
+	parts = append(parts, part)
+	indexes, err := indexesFromParts("postgres", parts)
+	is.NoErr(err)
+	is.Equal(2, len(indexes))
+	is.Equal("customer_store_id_data_idx", indexes[0].IndexName)
+	is.Equal([]string{"store_id", ""}, indexes[0].Columns)
+	is.Equal("customer_last_name_first_name_idx", indexes[1].IndexName)
+
+	query, err := createIndexQuery("postgres", indexes[0])
+	is.NoErr(err)
+	is.Equal("CREATE INDEX customer_store_id_data_idx ON customer (store_id, (CAST(SUBSTR(data, 1, 3) AS INT))) WHERE active = 1", query)
+	_, err = createIndexQuery("mysql", indexes[0])
+	is.True(err != nil)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE customer (customer_id INTEGER PRIMARY KEY, store_id INT, first_name TEXT, last_name TEXT, active INT, data JSON)")
+	is.NoErr(err)
+	for _, index := range indexes {
+		is.NoErr(EnsureIndex(db, "sqlite3", index))
+	}
+	gotIndexes, err := GetIndexes(db, "sqlite3", tableName)
+	is.NoErr(err)
+	is.Equal(2, len(gotIndexes))
+	for _, index := range indexes {
+		is.True(!indexChanged("sqlite3", gotIndexes[index.IndexName], index))
+	}
+	changed := indexes[0]
+	changed.Where = "active = 0"
+	is.True(indexChanged("sqlite3", gotIndexes[changed.IndexName], changed))
+}
+
+func TestNamedIndex(t *testing.T) {
+	is := testutil.New(t)
+	tableName := [2]string{"", "actor"}
+	var parts []indexPart
+	for _, modifier := range [][2]string{
+		{"last_name", "1 name=actor_name_lookup type=hash"},
+		{"first_name", "1 order=1"},
+		{"", ". cols=last_update name=actor_update_idx"},
+		{"email", "btree"},
+	} {
+		part, err := indexPartFromModifier("postgres", tableName, modifier[0], modifier[1])
+		is.NoErr(err)
+		parts = append(parts, part)
+	}
+	indexes, err := indexesFromParts("postgres", parts)
+	is.NoErr(err)
+	is.Equal(3, len(indexes))
+	is.Equal("actor_name_lookup", indexes[0].IndexName)
+	is.Equal("HASH", indexes[0].IndexType)
+	is.Equal([]string{"first_name", "last_name"}, indexes[0].Columns)
+	is.Equal("actor_update_idx", indexes[1].IndexName)
+	is.Equal("", indexes[1].IndexType)
+	is.Equal("actor_email_idx", indexes[2].IndexName)
+	is.Equal("BTREE", indexes[2].IndexType)
+
+	parts = parts[:0]
+	for _, value := range []string{"2 name=actor_a_idx", "2 name=actor_b_idx"} {
+		part, err := indexPartFromModifier("postgres", tableName, "first_name", value)
+		is.NoErr(err)
+		parts = append(parts, part)
+	}
+	_, err = indexesFromParts("postgres", parts)
+	is.True(err != nil)
+}
+
+func TestIndexKeyPartOptions(t *testing.T) {
+	is := testutil.New(t)
+	tableName := [2]string{"", "address"}
+	var parts []indexPart
+	for _, modifier := range [][2]string{
+		{"postal_code", "btree,text_pattern_ops"},
+		{"district", "2 collate=NOCASE desc"},
+		{"address", "2 prefix=10"},
+	} {
+		part, err := indexPartFromModifier("postgres", tableName, modifier[0], modifier[1])
+		is.NoErr(err)
+		parts = append(parts, part)
+	}
+	_, err := indexesFromParts("postgres", parts)
+	is.True(err != nil)
+	_, err = indexesFromParts("sqlite3", parts[:1])
+	is.True(err != nil)
+	indexes, err := indexesFromParts("postgres", parts[:1])
+	is.NoErr(err)
+	part, err := indexPartFromModifier("sqlite3", tableName, "address", "2")
+	is.NoErr(err)
+	sqliteIndexes, err := indexesFromParts("sqlite3", []indexPart{parts[1], part})
+	is.NoErr(err)
+	indexes = append(indexes, sqliteIndexes...)
+
+	query, err := createIndexQuery("postgres", indexes[0])
+	is.NoErr(err)
+	is.Equal("CREATE INDEX address_postal_code_idx ON address USING BTREE (postal_code text_pattern_ops)", query)
+	_, err = createIndexQuery("sqlite3", indexes[0])
+	is.True(err != nil)
+	plain := indexes[0]
+	plain.Opclasses = nil
+	is.True(indexChanged("sqlite3", plain, indexes[0]))
+
+	_, err = createIndexQuery("mysql", indexes[1])
+	is.True(err != nil)
+	query, err = createIndexQuery("mysql", Index{
+		TableName:     "address",
+		IndexName:     "address_address_idx",
+		Columns:       []string{"address"},
+		Directions:    []string{"DESC"},
+		PrefixLengths: []int{10},
+	})
+	is.NoErr(err)
+	is.Equal("CREATE INDEX address_address_idx ON address (address(10) DESC)", query)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE address (address_id INTEGER PRIMARY KEY, address TEXT, district TEXT)")
+	is.NoErr(err)
+	index := indexes[1]
+	is.NoErr(EnsureIndex(db, "sqlite3", index))
+	gotIndexes, err := GetIndexes(db, "sqlite3", tableName)
+	is.NoErr(err)
+	is.Equal([]string{"DESC", ""}, gotIndexes[index.IndexName].Directions)
+	is.True(!indexChanged("sqlite3", gotIndexes[index.IndexName], index))
+	index.Directions = nil
+	is.True(indexChanged("sqlite3", gotIndexes[index.IndexName], index))
+}
+
+func TestIndexInclude(t *testing.T) {
+	is := testutil.New(t)
+	part, err := indexPartFromModifier("postgres", [2]string{"", "rental"}, "", ". cols=customer_id include=rental_date,return_date")
+	is.NoErr(err)
+	indexes, err := indexesFromParts("postgres", []indexPart{part})
+	is.NoErr(err)
+	index := indexes[0]
+	is.Equal([]string{"rental_date", "return_date"}, index.Include)
+	query, err := createIndexQuery("postgres", index)
+	is.NoErr(err)
+	is.Equal("CREATE INDEX rental_customer_id_idx ON rental (customer_id) INCLUDE (rental_date, return_date)", query)
+	_, err = createIndexQuery("mysql", index)
+	is.True(err != nil)
+	_, err = createIndexQuery("sqlite3", index)
+	is.True(err != nil)
+
+	wider := index
+	wider.Columns = []string{"customer_id", "rental_date", "return_date"}
+	wider.Exprs = make([]string, 3)
+	wider.Include = nil
+	is.True(indexChanged("sqlite3", wider, index))
+	reordered := index
+	reordered.Include = []string{"return_date", "rental_date"}
+	is.True(!indexChanged("sqlite3", reordered, index))
+}
+
+func TestIndexTypes(t *testing.T) {
+	is := testutil.New(t)
+	index := Index{TableName: "film", IndexName: "film_title_idx", Columns: []string{"title"}}
+	index.IndexType = "sp-gist"
+	query, err := createIndexQuery("postgres", index)
+	is.NoErr(err)
+	is.Equal("CREATE INDEX film_title_idx ON film USING SPGIST (title)", query)
+	is.True(ValidateIndex("mysql", index) != nil)
+
+	index.IndexType = "hash"
+	query, err = createIndexQuery("mysql", index)
+	is.NoErr(err)
+	is.Equal("CREATE INDEX film_title_idx ON film (title) USING HASH", query)
+	is.True(ValidateIndex("sqlite3", index) != nil)
+
+	index.IndexType = "fulltext"
+	query, err = createIndexQuery("mysql", index)
+	is.NoErr(err)
+	is.Equal("CREATE FULLTEXT INDEX film_title_idx ON film (title)", query)
+	is.True(ValidateIndex("postgres", index) != nil)
+
+	index.IndexType = "btree"
+	query, err = createIndexQuery("sqlite3", index)
+	is.NoErr(err)
+	is.Equal("CREATE INDEX film_title_idx ON film (title)", query)
+
+	// MySQL reports BTREE for an index declared without a type, and for
+	// an InnoDB index declared USING HASH.
+	got := index
+	got.IndexType = "BTREE"
+	want := index
+	want.IndexType = ""
+	is.True(!indexChanged("mysql", got, want))
+	want.IndexType = "GIN"
+	is.True(indexChanged("mysql", got, want))
+	want.IndexType = "HASH"
+	is.True(!indexChanged("mysql", got, want))
+	is.True(indexChanged("postgres", got, want))
+
+	index.IndexSchema = "main"
+	query, err = createIndexQuery("sqlite3", index)
+	is.NoErr(err)
+	is.Equal("CREATE INDEX film_title_idx ON film (title)", query)
+}
+
+func TestIndexDeclarationValidation(t *testing.T) {
+	is := testutil.New(t)
+	tableName := [2]string{"", "film"}
+	_, err := indexPartFromModifier("mysql", tableName, "description", ". type=gin")
+	is.True(err != nil)
+	part, err := indexPartFromModifier("postgres", tableName, "description", ". type=gin")
+	is.NoErr(err)
+	is.Equal("GIN", part.index.IndexType)
+	_, err = indexesFromParts("mysql", []indexPart{part})
+	is.True(err != nil)
+
+	part, err = indexPartFromModifier("postgres", tableName, "description", "gin")
+	is.NoErr(err)
+	is.Equal("GIN", part.index.IndexType)
+	indexes, err := indexesFromParts("postgres", []indexPart{part})
+	is.NoErr(err)
+	query, err := createIndexQuery("postgres", indexes[0])
+	is.NoErr(err)
+	is.Equal("CREATE INDEX film_description_idx ON film USING GIN (description)", query)
+	_, err = indexPartFromModifier("sqlite3", tableName, "description", "gin")
+	is.True(err != nil)
+	_, err = indexPartFromModifier("mysql", tableName, "description", "gin")
+	is.True(err != nil)
+	_, err = indexPartFromModifier("postgres", tableName, "description", "fulltext")
+	is.True(err != nil)
+	part, err = indexPartFromModifier("mysql", tableName, "description", "fulltext")
+	is.NoErr(err)
+	is.Equal("FULLTEXT", part.index.IndexType)
+
+	FILM := struct{ TITLE, DESCRIPTION stringfield }{stringfield{"title"}, stringfield{"description"}}
+	index, err := indexFromFields("postgres", tableName, "", "", "gin", false, []Field{FILM.DESCRIPTION}, nil)
+	is.NoErr(err)
+	is.Equal("film_description_idx", index.IndexName)
+	is.Equal([]string{"description"}, index.Columns)
+	_, err = indexFromFields("mysql", tableName, "", "film_description_idx", "gin", false, []Field{FILM.DESCRIPTION}, nil)
+	is.True(err != nil)
+	_, err = indexFromFields("sqlite3", tableName, "", "film_title_idx", "", false, []Field{FILM.TITLE}, []Field{FILM.DESCRIPTION})
+	is.True(err != nil)
+}
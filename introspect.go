@@ -0,0 +1,196 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+var postgresRefOptions = map[string]RefOption{
+	"a": NoAction,
+	"r": Restrict,
+	"c": Cascade,
+	"n": SetNull,
+	"d": SetDefault,
+}
+
+// GetKeyConstraints returns the PRIMARY KEY and FOREIGN KEY constraints of a
+// table in the database, including the ones spanning multiple columns.
+func GetKeyConstraints(db DB, dialect string, tableName [2]string) (constraints map[string]TableConstraint, err error) {
+	switch dialect {
+	case "postgres":
+		return getPostgresKeyConstraints(db, tableName)
+	case "mysql":
+		return getMySQLKeyConstraints(db, tableName)
+	case "sqlite3":
+		return getSQLiteKeyConstraints(db, tableName)
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+func getPostgresKeyConstraints(db DB, tableName [2]string) (map[string]TableConstraint, error) {
+	const query = `SELECT c.conname, c.contype, tn.nspname, COALESCE(fn.nspname, ''), COALESCE(f.relname, '')
+	,(SELECT string_agg(a.attname, ',' ORDER BY k.ord) FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord) JOIN pg_attribute AS a ON a.attrelid = c.conrelid AND a.attnum = k.attnum)
+	,COALESCE((SELECT string_agg(a.attname, ',' ORDER BY k.ord) FROM unnest(c.confkey) WITH ORDINALITY AS k(attnum, ord) JOIN pg_attribute AS a ON a.attrelid = c.confrelid AND a.attnum = k.attnum), '')
+	,c.confupdtype, c.confdeltype
+FROM pg_constraint AS c
+JOIN pg_class AS t ON t.oid = c.conrelid
+JOIN pg_namespace AS tn ON tn.oid = t.relnamespace
+LEFT JOIN pg_class AS f ON f.oid = c.confrelid
+LEFT JOIN pg_namespace AS fn ON fn.oid = f.relnamespace
+WHERE c.contype IN ('p', 'f') AND tn.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND t.relname = $2`
+	rows, err := db.Query(query, tableName[0], tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	constraints := make(map[string]TableConstraint)
+	for rows.Next() {
+		constraint := TableConstraint{TableSchema: tableName[0], TableName: tableName[1]}
+		var contype, tableSchema, columns, refColumns, onUpdate, onDelete string
+		err = rows.Scan(&constraint.ConstraintName, &contype, &tableSchema, &constraint.ReferencesSchema, &constraint.ReferencesTable, &columns, &refColumns, &onUpdate, &onDelete)
+		if err != nil {
+			return nil, err
+		}
+		constraint.ReferencesSchema = referencesSchema(tableName, tableSchema, constraint.ReferencesSchema)
+		constraint.Columns = strings.Split(columns, ",")
+		if contype == "p" {
+			constraint.ConstraintType = "PRIMARY KEY"
+		} else {
+			constraint.ConstraintType = "FOREIGN KEY"
+			constraint.ReferencesColumns = strings.Split(refColumns, ",")
+			constraint.OnUpdate.String, constraint.OnUpdate.Valid = string(postgresRefOptions[onUpdate]), true
+			constraint.OnDelete.String, constraint.OnDelete.Valid = string(postgresRefOptions[onDelete]), true
+		}
+		constraints[constraint.ConstraintName] = constraint
+	}
+	return constraints, rows.Err()
+}
+
+func getMySQLKeyConstraints(db DB, tableName [2]string) (map[string]TableConstraint, error) {
+	const query = `SELECT kcu.CONSTRAINT_NAME, tc.CONSTRAINT_TYPE, kcu.TABLE_SCHEMA, kcu.COLUMN_NAME
+	,COALESCE(kcu.REFERENCED_TABLE_SCHEMA, ''), COALESCE(kcu.REFERENCED_TABLE_NAME, ''), COALESCE(kcu.REFERENCED_COLUMN_NAME, '')
+	,COALESCE(rc.UPDATE_RULE, ''), COALESCE(rc.DELETE_RULE, '')
+FROM information_schema.KEY_COLUMN_USAGE AS kcu
+JOIN information_schema.TABLE_CONSTRAINTS AS tc ON tc.CONSTRAINT_SCHEMA = kcu.CONSTRAINT_SCHEMA AND tc.TABLE_NAME = kcu.TABLE_NAME AND tc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME
+LEFT JOIN information_schema.REFERENTIAL_CONSTRAINTS AS rc ON rc.CONSTRAINT_SCHEMA = kcu.CONSTRAINT_SCHEMA AND rc.CONSTRAINT_NAME = kcu.CONSTRAINT_NAME
+WHERE tc.CONSTRAINT_TYPE IN ('PRIMARY KEY', 'FOREIGN KEY') AND kcu.TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND kcu.TABLE_NAME = ?
+ORDER BY kcu.CONSTRAINT_NAME, kcu.ORDINAL_POSITION`
+	rows, err := db.Query(query, tableName[0], tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	constraints := make(map[string]TableConstraint)
+	for rows.Next() {
+		var name, constraintType, tableSchema, column, refSchema, refTable, refColumn, onUpdate, onDelete string
+		err = rows.Scan(&name, &constraintType, &tableSchema, &column, &refSchema, &refTable, &refColumn, &onUpdate, &onDelete)
+		if err != nil {
+			return nil, err
+		}
+		constraint, ok := constraints[name]
+		if !ok {
+			constraint = TableConstraint{
+				TableSchema:      tableName[0],
+				TableName:        tableName[1],
+				ConstraintName:   name,
+				ConstraintType:   constraintType,
+				ReferencesSchema: referencesSchema(tableName, tableSchema, refSchema),
+				ReferencesTable:  refTable,
+			}
+			if constraintType == "FOREIGN KEY" {
+				constraint.OnUpdate.String, constraint.OnUpdate.Valid = onUpdate, true
+				constraint.OnDelete.String, constraint.OnDelete.Valid = onDelete, true
+			}
+		}
+		constraint.Columns = append(constraint.Columns, column)
+		if refColumn != "" {
+			constraint.ReferencesColumns = append(constraint.ReferencesColumns, refColumn)
+		}
+		constraints[name] = constraint
+	}
+	return constraints, rows.Err()
+}
+
+// referencesSchema reports a referenced table in the same schema as the
+// referencing table under the schema the table was asked for, so that a
+// reference declared without a schema matches the one introspected.
+func referencesSchema(tableName [2]string, tableSchema, refSchema string) string {
+	if refSchema == tableSchema {
+		return tableName[0]
+	}
+	return refSchema
+}
+
+// getSQLiteKeyConstraints names the constraints it finds following Postgres'
+// naming convention, because SQLite does not report constraint names.
+func getSQLiteKeyConstraints(db DB, tableName [2]string) (map[string]TableConstraint, error) {
+	constraints := make(map[string]TableConstraint)
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var pkColumns []string
+	for rows.Next() {
+		var column string
+		err = rows.Scan(&column)
+		if err != nil {
+			return nil, err
+		}
+		pkColumns = append(pkColumns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(pkColumns) > 0 {
+		name := defaultConstraintName("sqlite3", tableName[1], "PRIMARY KEY", pkColumns)
+		constraints[name] = TableConstraint{
+			TableSchema:    tableName[0],
+			TableName:      tableName[1],
+			ConstraintName: name,
+			ConstraintType: "PRIMARY KEY",
+			Columns:        pkColumns,
+		}
+	}
+	rows, err = db.Query(`SELECT id, "table", "from", COALESCE("to", ''), on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`, tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var foreignKeys []TableConstraint
+	var ids []int
+	for rows.Next() {
+		var id int
+		var refTable, column, refColumn, onUpdate, onDelete string
+		err = rows.Scan(&id, &refTable, &column, &refColumn, &onUpdate, &onDelete)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 || ids[len(ids)-1] != id {
+			ids = append(ids, id)
+			constraint := TableConstraint{
+				TableSchema:     tableName[0],
+				TableName:       tableName[1],
+				ConstraintType:  "FOREIGN KEY",
+				ReferencesTable: refTable,
+			}
+			constraint.OnUpdate.String, constraint.OnUpdate.Valid = onUpdate, true
+			constraint.OnDelete.String, constraint.OnDelete.Valid = onDelete, true
+			foreignKeys = append(foreignKeys, constraint)
+		}
+		constraint := &foreignKeys[len(foreignKeys)-1]
+		constraint.Columns = append(constraint.Columns, column)
+		if refColumn != "" {
+			constraint.ReferencesColumns = append(constraint.ReferencesColumns, refColumn)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, constraint := range foreignKeys {
+		constraint.ConstraintName = defaultConstraintName("sqlite3", tableName[1], "FOREIGN KEY", constraint.Columns)
+		constraints[constraint.ConstraintName] = constraint
+	}
+	return constraints, nil
+}
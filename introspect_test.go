@@ -0,0 +1,33 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestGetKeyConstraints(t *testing.T) {
+	is := testutil.New(t)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	filmActor, err := tableConstraintFromModifier("sqlite3", [2]string{"", "film_actor"}, [2]string{"primarykey", ". cols=actor_id,film_id"})
+	is.NoErr(err)
+	rentalActor, err := tableConstraintFromModifier("sqlite3", [2]string{"", "rental_actor"}, [2]string{"foreignkey", ". cols=actor_id,film_id references={film_actor cols=actor_id,film_id} onupdate=cascade ondelete=restrict"})
+	is.NoErr(err)
+	for _, constraint := range []TableConstraint{filmActor, rentalActor} {
+		definition, err := constraintDefinition("sqlite3", constraint)
+		is.NoErr(err)
+		_, err = db.Exec("CREATE TABLE " + constraint.TableName + " (actor_id INT NOT NULL, film_id INT NOT NULL, " + definition + ")")
+		is.NoErr(err)
+	}
+
+	constraints, err := GetKeyConstraints(db, "sqlite3", [2]string{"", "film_actor"})
+	is.NoErr(err)
+	is.Equal(map[string]TableConstraint{filmActor.ConstraintName: filmActor}, constraints)
+
+	constraints, err = GetKeyConstraints(db, "sqlite3", [2]string{"", "rental_actor"})
+	is.NoErr(err)
+	is.Equal(map[string]TableConstraint{rentalActor.ConstraintName: rentalActor}, constraints)
+}
@@ -29,13 +29,13 @@ type Column struct {
 	TableName                string
 	ColumnName               string
 	ColumnType               string
-	Autoincrement            int // None | ROWID | ROWID_AUTOINCREMENT | IDENTITY | SERIAL | AUTO_INCREMENT
+	Autoincrement            int // None | ROWID | ROWID_AUTOINCREMENT | IDENTITY | SERIAL | AUTO_INCREMENT | ALWAYS IDENTITY
 	IsNotNull                bool
 	IsPrimaryKey             bool // must not be set for multicolumn primary keys
 	IsUnique                 bool
-	GeneratedStored          bool           // SQLite cannot provide this info, need to parse DDL
-	GeneratedExpr            sql.NullString // SQLite cannot provide this info, need to parse DDL
-	Collation                sql.NullString // SQLite cannot provide this info, need to parse DDL
+	GeneratedStored          bool           // SQLite cannot provide this info, see ParseSQLiteCreateTable
+	GeneratedExpr            sql.NullString // SQLite cannot provide this info, see ParseSQLiteCreateTable
+	Collation                sql.NullString // SQLite cannot provide this info, see ParseSQLiteCreateTable
 	ColumnDefault            sql.NullString
 	ReferencesSchema         sql.NullString
 	ReferencesTable          sql.NullString
@@ -43,15 +43,21 @@ type Column struct {
 	ReferencesOnUpdate       sql.NullString
 	ReferencesOnDelete       sql.NullString
 	OnUpdateCurrentTimestamp sql.NullBool
+	Comment                  sql.NullString
 }
 
 type TableConstraint struct {
-	TableSchema    string
-	TableName      string
-	ConstraintName string
-	ConstraintType string // PRIMARY KEY | UNIQUE | CHECK
-	Columns        []string
-	CheckExpr      sql.NullString
+	TableSchema       string
+	TableName         string
+	ConstraintName    string
+	ConstraintType    string // PRIMARY KEY | UNIQUE | CHECK | FOREIGN KEY
+	Columns           []string
+	CheckExpr         sql.NullString
+	ReferencesSchema  string
+	ReferencesTable   string
+	ReferencesColumns []string
+	OnUpdate          sql.NullString
+	OnDelete          sql.NullString
 }
 
 type Index struct {
@@ -66,6 +72,14 @@ type Index struct {
 	Columns     []string
 	Exprs       []string
 	Include     []string
+	Online      bool // CREATE INDEX CONCURRENTLY | ALGORITHM=INPLACE LOCK=NONE
+	// Key part options, parallel to Columns. An empty (or missing) entry
+	// means the dialect's default.
+	Directions    []string // ASC | DESC
+	NullsOrders   []string // NULLS FIRST | NULLS LAST
+	Opclasses     []string // Postgres only, e.g. text_pattern_ops
+	Collations    []string
+	PrefixLengths []int // MySQL only
 }
 
 type GotTables interface {
@@ -81,3 +95,11 @@ type WantTables interface {
 	CreateColumn(tableName [2]string, columnName string) (query string, args []interface{}, err error)
 	CreateIndex(indexName [2]string) (query string, args []interface{}, err error)
 }
+
+// ForeignKeyDeferrer is implemented by WantTables that can leave foreign keys
+// out of a table's CREATE TABLE. EnsureTables uses it to create tables whose
+// foreign keys form a cycle, adding those foreign keys once every table
+// exists.
+type ForeignKeyDeferrer interface {
+	CreateTableWithoutForeignKeys(tableName [2]string, constraintNames []string) (querylist []string, argslist [][]interface{}, err error)
+}
@@ -1,12 +1,63 @@
 package metadata
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+)
 
 type tableinfo [2]string
 
 func (t tableinfo) GetSchema() string { return t[0] }
 func (t tableinfo) GetName() string   { return t[1] }
 
+type mockTables struct {
+	tables      [][2]string
+	columns     map[[2]string]map[string]Column
+	constraints map[[2]string]map[string]TableConstraint
+	indices     map[[2]string]map[[2]string]Index
+	createTable map[[2]string][]string
+}
+
+func (m mockTables) GetTables() (tableNames [][2]string, err error) { return m.tables, nil }
+
+func (m mockTables) GetColumns(tableName [2]string) (columns map[string]Column, err error) {
+	return m.columns[tableName], nil
+}
+
+func (m mockTables) GetConstraints(tableName [2]string) (constraints map[string]TableConstraint, err error) {
+	return m.constraints[tableName], nil
+}
+
+func (m mockTables) GetIndices(tableName [2]string) (indices map[[2]string]Index, err error) {
+	return m.indices[tableName], nil
+}
+
+func (m mockTables) CreateTable(tableName [2]string) (querylist []string, argslist [][]interface{}, err error) {
+	querylist, ok := m.createTable[tableName]
+	if !ok {
+		return nil, nil, fmt.Errorf("no such table %v", tableName)
+	}
+	return querylist, nil, nil
+}
+
+func (m mockTables) CreateColumn(tableName [2]string, columnName string) (query string, args []interface{}, err error) {
+	column, ok := m.columns[tableName][columnName]
+	if !ok {
+		return "", nil, fmt.Errorf("no such column %s", columnName)
+	}
+	return "ALTER TABLE " + tableName[1] + " ADD COLUMN " + columnDefinition("", column), nil, nil
+}
+
+func (m mockTables) CreateIndex(indexName [2]string) (query string, args []interface{}, err error) {
+	for _, indices := range m.indices {
+		if index, ok := indices[indexName]; ok {
+			query, err = createIndexQuery("", index)
+			return query, nil, err
+		}
+	}
+	return "", nil, fmt.Errorf("no such index %v", indexName)
+}
+
 type field string
 type blobfield struct{ field }
 type booleanfield struct{ field }
@@ -38,6 +89,7 @@ type _ACTOR struct {
 }
 
 func (ACTOR _ACTOR) Constraints(dialect string, c *C) {
+	c.Col(ACTOR.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
@@ -51,7 +103,7 @@ func (ACTOR _ACTOR) Constraints(dialect string, c *C) {
 		c.Col(ACTOR.LAST_NAME, c.Type("VARCHAR(45)"))
 		c.Col(ACTOR.FULL_NAME, c.Type("VARCHAR(45)"), c.Generated("CONCAT(first_name, ' ', last_name)", false))
 		c.Col(ACTOR.FULL_NAME_REVERSED, c.Type("VARCHAR(45)"), c.Generated("CONCAT(last_name, ' ', first_name)", true))
-		c.Col(ACTOR.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(ACTOR.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -63,6 +115,7 @@ type _CATEGORY struct {
 }
 
 func (CATEGORY _CATEGORY) Constraints(dialect string, c *C) {
+	c.Col(CATEGORY.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
@@ -72,7 +125,7 @@ func (CATEGORY _CATEGORY) Constraints(dialect string, c *C) {
 		c.TableSchema("db")
 		c.Col(CATEGORY.CATEGORY_ID, c.Autoincrement(AutoincrementMySQL))
 		c.Col(CATEGORY.NAME, c.Type("VARCHAR(25)"))
-		c.Col(CATEGORY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(CATEGORY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -84,6 +137,7 @@ type _COUNTRY struct {
 }
 
 func (COUNTRY _COUNTRY) Constraints(dialect string, c *C) {
+	c.Col(COUNTRY.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
@@ -93,7 +147,7 @@ func (COUNTRY _COUNTRY) Constraints(dialect string, c *C) {
 		c.TableSchema("db")
 		c.Col(COUNTRY.COUNTRY_ID, c.Autoincrement(AutoincrementMySQL))
 		c.Col(COUNTRY.COUNTRY, c.Type("VARCHAR(50)"))
-		c.Col(COUNTRY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(COUNTRY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -106,6 +160,7 @@ type _CITY struct {
 }
 
 func (CITY _CITY) Constraints(dialect string, c *C) {
+	c.Col(CITY.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
@@ -115,7 +170,7 @@ func (CITY _CITY) Constraints(dialect string, c *C) {
 		c.TableSchema("db")
 		c.Col(CITY.CITY_ID, c.Autoincrement(AutoincrementMySQL))
 		c.Col(CITY.CITY, c.Type("VARCHAR(50)"))
-		c.Col(CITY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(CITY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -132,6 +187,7 @@ type _ADDRESS struct {
 }
 
 func (ADDRESS _ADDRESS) Constraints(dialect string, c *C) {
+	c.Col(ADDRESS.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
@@ -145,7 +201,7 @@ func (ADDRESS _ADDRESS) Constraints(dialect string, c *C) {
 		c.Col(ADDRESS.DISTRICT, c.Type("VARCHAR(20)"))
 		c.Col(ADDRESS.POSTAL_CODE, c.Type("VARCHAR(10)"))
 		c.Col(ADDRESS.PHONE, c.Type("VARCHAR(20)"))
-		c.Col(ADDRESS.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(ADDRESS.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -157,6 +213,7 @@ type _LANGUAGE struct {
 }
 
 func (LANGUAGE _LANGUAGE) Constraints(dialect string, c *C) {
+	c.Col(LANGUAGE.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
@@ -166,7 +223,7 @@ func (LANGUAGE _LANGUAGE) Constraints(dialect string, c *C) {
 		c.TableSchema("db")
 		c.Col(LANGUAGE.LANGUAGE_ID, c.Autoincrement(AutoincrementMySQL))
 		c.Col(LANGUAGE.NAME, c.Type("CHAR(20)"))
-		c.Col(LANGUAGE.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(LANGUAGE.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -188,27 +245,37 @@ type _FILM struct {
 	FULLTEXT             stringfield `ddl:"notnull"`
 }
 
+var yearDomain = Domain{
+	DomainName: "year",
+	BaseType:   "INT",
+	Checks:     map[string]string{"year_check": "VALUE >= 1901 AND VALUE <= 2155"},
+}
+
 func (FILM _FILM) Constraints(dialect string, c *C) {
+	c.Col(FILM.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
+	c.Col(FILM.RELEASE_YEAR, c.Domain(yearDomain))
+	c.Col(FILM.RATING, c.Enum("", "mpaa_rating", "G", "PG", "PG-13", "R", "NC-17"))
 	switch dialect {
 	case "postgres":
 		c.TableSchema("public")
 		c.Col(FILM.FILM_ID, c.Autoincrement(AutoincrementDefaultIdentity))
-		c.Col(FILM.RELEASE_YEAR, c.Type("year"))
-		c.Col(FILM.RATING, c.Type("mpaa_rating"), c.Default("'G'::mpaa_rating"))
+		c.Col(FILM.RATING, c.Default("'G'::mpaa_rating"))
 		c.Col(FILM.LAST_UPDATE, c.Type("TIMESTAMPTZ"), c.Default("NOW()"))
 		c.Col(FILM.SPECIAL_FEATURES, c.Type("TEXT[]")) // TODO: ArrayField
-		c.Col(FILM.FULLTEXT, c.Type("TSVECTOR"))
+		c.Col(FILM.FULLTEXT, c.FullText("english", "GIST", FILM.TITLE, FILM.DESCRIPTION))
 	case "mysql":
 		c.TableSchema("db")
 		c.Col(FILM.FILM_ID, c.Autoincrement(AutoincrementMySQL))
 		c.Col(FILM.TITLE, c.Type("VARCHAR(255)"))
 		c.Col(FILM.DESCRIPTION, c.Type("TEXT"))
-		c.Col(FILM.RATING, c.Type("ENUM('G','PG','PG-13','R','NC-17')"))
-		c.Col(FILM.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
-		c.CheckString("film_release_year_check", "release_year >= 1901 AND release_year <= 2155")
-	case "sqlite3":
-		c.CheckString("film_release_year_check", "release_year >= 1901 AND release_year <= 2155")
-		c.CheckString("film_rating_check", "rating IN ('G','PG','PG-13','R','NC-17')")
+		c.Col(FILM.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
+		c.Trigger(Trigger{
+			TriggerName: "film_after_insert_trg",
+			Timing:      "AFTER",
+			Events:      []string{"INSERT"},
+			Body: "BEGIN INSERT INTO film_text (film_id, title, description)" +
+				" VALUES (NEW.film_id, NEW.title, NEW.description); END",
+		})
 	}
 }
 
@@ -239,14 +306,25 @@ type _FILM_ACTOR struct {
 }
 
 func (FILM_ACTOR _FILM_ACTOR) Constraints(dialect string, c *C) {
+	c.Col(FILM_ACTOR.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(FILM_ACTOR.LAST_UPDATE, c.Type("TIMESTAMPTZ"), c.Default("NOW()"))
 	case "mysql":
-		c.Col(FILM_ACTOR.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(FILM_ACTOR.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
+// _FILM_ACTOR_CREDIT is keyed by a composite primary key and references
+// film_actor through the composite unique index on its actor_id and film_id.
+type _FILM_ACTOR_CREDIT struct {
+	tableinfo    `ddl:"name=film_actor_credit primarykey={. cols=actor_id,film_id,credit_order} foreignkey={. cols=actor_id,film_id references={film_actor cols=actor_id,film_id} ondelete=cascade}"`
+	ACTOR_ID     numberfield `ddl:"notnull"`
+	FILM_ID      numberfield `ddl:"notnull"`
+	CREDIT_ORDER numberfield `ddl:"notnull"`
+	ROLE_NAME    stringfield
+}
+
 type _FILM_CATEGORY struct {
 	tableinfo   `ddl:"name=film_category"`
 	FILM_ID     numberfield `ddl:"notnull references={film onupdate=cascade ondelete=restrict}"`
@@ -255,11 +333,12 @@ type _FILM_CATEGORY struct {
 }
 
 func (FILM_CATEGORY _FILM_CATEGORY) Constraints(dialect string, c *C) {
+	c.Col(FILM_CATEGORY.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(FILM_CATEGORY.LAST_UPDATE, c.Type("TIMESTAMPTZ"), c.Default("NOW()"))
 	case "mysql":
-		c.Col(FILM_CATEGORY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(FILM_CATEGORY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -279,6 +358,7 @@ type _STAFF struct {
 }
 
 func (STAFF _STAFF) Constraints(dialect string, c *C) {
+	c.Col(STAFF.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(STAFF.STAFF_ID, c.Autoincrement(AutoincrementDefaultIdentity))
@@ -291,7 +371,7 @@ func (STAFF _STAFF) Constraints(dialect string, c *C) {
 		c.Col(STAFF.EMAIL, c.Type("VARCHAR(50)"))
 		c.Col(STAFF.USERNAME, c.Type("VARCHAR(16)"))
 		c.Col(STAFF.PASSWORD, c.Type("VARCHAR(40)"))
-		c.Col(STAFF.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(STAFF.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -304,13 +384,14 @@ type _STORE struct {
 }
 
 func (STORE _STORE) Constraints(dialect string, c *C) {
+	c.Col(STORE.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(STORE.STORE_ID, c.Autoincrement(AutoincrementDefaultIdentity))
 		c.Col(STORE.LAST_UPDATE, c.Type("TIMESTAMPTZ"), c.Default("NOW()"))
 	case "mysql":
 		c.Col(STORE.STORE_ID, c.Autoincrement(AutoincrementMySQL))
-		c.Col(STORE.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(STORE.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -329,6 +410,7 @@ type _CUSTOMER struct {
 }
 
 func (CUSTOMER _CUSTOMER) Constraints(dialect string, c *C) {
+	c.Col(CUSTOMER.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(CUSTOMER.CUSTOMER_ID, c.Autoincrement(AutoincrementDefaultIdentity))
@@ -340,7 +422,7 @@ func (CUSTOMER _CUSTOMER) Constraints(dialect string, c *C) {
 		c.Col(CUSTOMER.LAST_NAME, c.Type("VARCHAR(45)"))
 		c.Col(CUSTOMER.EMAIL, c.Type("VARCHAR(50)"))
 		c.Col(CUSTOMER.CREATE_DATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
-		c.Col(CUSTOMER.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(CUSTOMER.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -353,13 +435,14 @@ type _INVENTORY struct {
 }
 
 func (INVENTORY _INVENTORY) Constraints(dialect string, c *C) {
+	c.Col(INVENTORY.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(INVENTORY.INVENTORY_ID, c.Autoincrement(AutoincrementDefaultIdentity))
 		c.Col(INVENTORY.LAST_UPDATE, c.Type("TIMESTAMPTZ"), c.Default("NOW()"))
 	case "mysql":
 		c.Col(INVENTORY.INVENTORY_ID, c.Autoincrement(AutoincrementMySQL))
-		c.Col(INVENTORY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(INVENTORY.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -375,6 +458,7 @@ type _RENTAL struct {
 }
 
 func (RENTAL _RENTAL) Constraints(dialect string, c *C) {
+	c.Col(RENTAL.LAST_UPDATE, c.OnUpdateCurrentTimestamp)
 	switch dialect {
 	case "postgres":
 		c.Col(RENTAL.RENTAL_ID, c.Autoincrement(AutoincrementDefaultIdentity))
@@ -383,7 +467,7 @@ func (RENTAL _RENTAL) Constraints(dialect string, c *C) {
 	case "mysql":
 		c.Col(RENTAL.RENTAL_ID, c.Autoincrement(AutoincrementMySQL))
 		c.Col(RENTAL.RETURN_DATE, c.Type("TIMESTAMP"))
-		c.Col(RENTAL.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"), c.OnUpdateCurrentTimestamp)
+		c.Col(RENTAL.LAST_UPDATE, c.Type("TIMESTAMP"), c.Default("CURRENT_TIMESTAMP"))
 	}
 }
 
@@ -0,0 +1,104 @@
+package metadata
+
+import "sort"
+
+// SortTables orders the tables in gotTables so that every table comes after
+// the tables it references, through either a column's References* fields or
+// a FOREIGN KEY table constraint. References that form a cycle (like staff
+// and store referencing each other) cannot be satisfied by ordering alone;
+// they are returned as deferred and should be left out of CREATE TABLE and
+// added with AddConstraintQuery once every table exists. SQLite does not
+// check that a referenced table exists when creating a table, so it can keep
+// deferred references in CREATE TABLE instead.
+func SortTables(gotTables GotTables) (tableNames [][2]string, deferred []TableConstraint, err error) {
+	tables, err := gotTables.GetTables()
+	if err != nil {
+		return nil, nil, err
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[[2]string]int)
+	for _, tableName := range tables {
+		state[tableName] = unvisited
+	}
+	var visit func(tableName [2]string) error
+	visit = func(tableName [2]string) error {
+		state[tableName] = visiting
+		foreignKeys, err := getForeignKeys(gotTables, tableName)
+		if err != nil {
+			return err
+		}
+		for _, foreignKey := range foreignKeys {
+			refTableName := [2]string{foreignKey.ReferencesSchema, foreignKey.ReferencesTable}
+			if refTableName == tableName {
+				continue
+			}
+			refState, ok := state[refTableName]
+			if !ok {
+				continue
+			}
+			switch refState {
+			case visiting:
+				deferred = append(deferred, foreignKey)
+			case unvisited:
+				err = visit(refTableName)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state[tableName] = visited
+		tableNames = append(tableNames, tableName)
+		return nil
+	}
+	for _, tableName := range tables {
+		if state[tableName] != unvisited {
+			continue
+		}
+		err = visit(tableName)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return tableNames, deferred, nil
+}
+
+// getForeignKeys returns every foreign key of a table, sorted by name.
+func getForeignKeys(gotTables GotTables, tableName [2]string) ([]TableConstraint, error) {
+	columns, err := gotTables.GetColumns(tableName)
+	if err != nil {
+		return nil, err
+	}
+	constraints, err := gotTables.GetConstraints(tableName)
+	if err != nil {
+		return nil, err
+	}
+	var foreignKeys []TableConstraint
+	for _, column := range columns {
+		if column.ReferencesTable.Valid {
+			foreignKeys = append(foreignKeys, columnForeignKey(column))
+		}
+	}
+	for _, constraint := range constraints {
+		if constraint.ConstraintType == "FOREIGN KEY" {
+			foreignKeys = append(foreignKeys, constraint)
+		}
+	}
+	// A reference without a schema is to a table in the referencing table's
+	// schema.
+	for i := range foreignKeys {
+		if foreignKeys[i].ReferencesSchema == "" {
+			foreignKeys[i].ReferencesSchema = tableName[0]
+		}
+		if foreignKeys[i].TableSchema == "" {
+			foreignKeys[i].TableSchema = tableName[0]
+		}
+	}
+	sort.Slice(foreignKeys, func(i, j int) bool {
+		return foreignKeys[i].ConstraintName < foreignKeys[j].ConstraintName
+	})
+	return foreignKeys, nil
+}
@@ -0,0 +1,67 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestSortTables(t *testing.T) {
+	is := testutil.New(t)
+	references := func(tableName, columnName, refTable string) Column {
+		return Column{
+			TableName:          tableName,
+			ColumnName:         columnName,
+			ReferencesTable:    sql.NullString{String: refTable, Valid: true},
+			ReferencesColumn:   sql.NullString{String: refTable + "_id", Valid: true},
+			ReferencesOnUpdate: sql.NullString{String: string(Cascade), Valid: true},
+			ReferencesOnDelete: sql.NullString{String: string(Restrict), Valid: true},
+		}
+	}
+	country, city, address := [2]string{"", "country"}, [2]string{"", "city"}, [2]string{"", "address"}
+	staff, store := [2]string{"", "staff"}, [2]string{"", "store"}
+	gotTables := mockTables{
+		tables: [][2]string{staff, store, address, city, country},
+		columns: map[[2]string]map[string]Column{
+			country: {"country_id": {TableName: "country", ColumnName: "country_id"}},
+			city:    {"country_id": references("city", "country_id", "country")},
+			address: {"city_id": references("address", "city_id", "city")},
+			staff: {
+				"address_id": references("staff", "address_id", "address"),
+				"store_id":   references("staff", "store_id", "store"),
+			},
+			store: {
+				"address_id":       references("store", "address_id", "address"),
+				"manager_staff_id": references("store", "manager_staff_id", "staff"),
+			},
+		},
+	}
+	tableNames, deferred, err := SortTables(gotTables)
+	is.NoErr(err)
+	is.Equal([][2]string{country, city, address, store, staff}, tableNames)
+	is.Equal(1, len(deferred))
+	is.Equal("store_manager_staff_id_fkey", deferred[0].ConstraintName)
+
+	query, err := AddConstraintQuery("postgres", deferred[0])
+	is.NoErr(err)
+	is.Equal("ALTER TABLE store ADD CONSTRAINT store_manager_staff_id_fkey FOREIGN KEY (manager_staff_id) REFERENCES staff (staff_id) ON UPDATE CASCADE ON DELETE RESTRICT", query)
+	_, err = AddConstraintQuery("sqlite3", deferred[0])
+	is.True(err != nil)
+
+	// References without a schema are to tables in the referencing table's
+	// schema.
+	schemaTables := mockTables{columns: make(map[[2]string]map[string]Column)}
+	for _, tableName := range gotTables.tables {
+		tableName := [2]string{"public", tableName[1]}
+		schemaTables.tables = append(schemaTables.tables, tableName)
+		schemaTables.columns[tableName] = gotTables.columns[[2]string{"", tableName[1]}]
+	}
+	tableNames, deferred, err = SortTables(schemaTables)
+	is.NoErr(err)
+	is.Equal([][2]string{{"public", "country"}, {"public", "city"}, {"public", "address"}, {"public", "store"}, {"public", "staff"}}, tableNames)
+	is.Equal(1, len(deferred))
+	query, err = AddConstraintQuery("postgres", deferred[0])
+	is.NoErr(err)
+	is.Equal("ALTER TABLE public.store ADD CONSTRAINT store_manager_staff_id_fkey FOREIGN KEY (manager_staff_id) REFERENCES public.staff (staff_id) ON UPDATE CASCADE ON DELETE RESTRICT", query)
+}
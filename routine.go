@@ -0,0 +1,444 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Routine is a stored function or procedure. Arguments is the argument list
+// without the surrounding parentheses, Returns is the return type of a
+// function and Characteristics holds everything else that goes between the
+// signature and the body, such as LANGUAGE plpgsql or READS SQL DATA. A
+// routine is identified by its name and, on Postgres, the types of its
+// arguments (so that overloads are told apart). It is recreated whenever its
+// arguments, return type, characteristics or body differ from the ones in the
+// database.
+type Routine struct {
+	RoutineSchema   string
+	RoutineName     string
+	RoutineType     string // FUNCTION | PROCEDURE
+	Arguments       string
+	Returns         string
+	Characteristics string
+	Body            string
+}
+
+func createRoutineQuery(dialect string, routine Routine) (string, error) {
+	routineType := strings.ToUpper(routine.RoutineType)
+	if routineType == "" {
+		routineType = "FUNCTION"
+	}
+	if routineType != "FUNCTION" && routineType != "PROCEDURE" {
+		return "", fmt.Errorf("routine %s: invalid routine type %s", routine.RoutineName, routine.RoutineType)
+	}
+	if routineType == "FUNCTION" && routine.Returns == "" {
+		return "", fmt.Errorf("function %s has no return type", routine.RoutineName)
+	}
+	buf := &strings.Builder{}
+	switch dialect {
+	case "postgres":
+		buf.WriteString("CREATE OR REPLACE ")
+	case "mysql":
+		buf.WriteString("CREATE ")
+	default:
+		return "", fmt.Errorf("%s does not support stored routines", dialect)
+	}
+	buf.WriteString(routineType + " " + qualifiedName(dialect, routine.RoutineSchema, routine.RoutineName) + "(" + routine.Arguments + ")")
+	if routineType == "FUNCTION" {
+		buf.WriteString(" RETURNS " + routine.Returns)
+	}
+	if routine.Characteristics != "" {
+		buf.WriteString(" " + routine.Characteristics)
+	}
+	if dialect == "postgres" {
+		tag := "$$"
+		if strings.Contains(routine.Body, tag) {
+			tag = "$body$"
+		}
+		buf.WriteString(" AS " + tag + routine.Body + tag)
+	} else {
+		buf.WriteString(" " + routine.Body)
+	}
+	return buf.String(), nil
+}
+
+// splitArguments splits an argument list on the commas that are not nested
+// inside parentheses, like the one in NUMERIC(5,2).
+func splitArguments(arguments string) []string {
+	var args []string
+	var depth, start int
+	for i, r := range arguments {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, arguments[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(arguments[start:]) != "" {
+		args = append(args, arguments[start:])
+	}
+	return args
+}
+
+// multiwordTypes are the types whose first word could otherwise be mistaken
+// for an argument name.
+var multiwordTypes = map[string]bool{
+	"double":    true,
+	"character": true,
+	"timestamp": true,
+	"time":      true,
+	"bit":       true,
+	"interval":  true,
+}
+
+// routineArgument is one argument of an argument list. Everything but the
+// type may be empty.
+type routineArgument struct {
+	mode         string
+	name         string
+	typ          string
+	defaultValue string
+}
+
+// splitFields splits an argument on the whitespace that is not nested inside
+// parentheses, so that NUMERIC(5, 2) and NUMERIC (5, 2) are a single field.
+func splitFields(arg string) []string {
+	var fields []string
+	var depth int
+	start := -1
+	for i, r := range arg {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				if start >= 0 {
+					fields = append(fields, arg[start:i])
+					start = -1
+				}
+				continue
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, arg[start:])
+	}
+	merged := fields[:0:0]
+	for _, field := range fields {
+		if field[0] == '(' && len(merged) > 0 {
+			merged[len(merged)-1] += field
+			continue
+		}
+		merged = append(merged, field)
+	}
+	return merged
+}
+
+func parseRoutineArgument(arg string) routineArgument {
+	var argument routineArgument
+	fields := splitFields(arg)
+	for i, field := range fields {
+		if strings.EqualFold(field, "DEFAULT") || field == "=" {
+			argument.defaultValue = strings.Join(fields[i+1:], " ")
+			fields = fields[:i]
+			break
+		}
+	}
+	if len(fields) > 0 {
+		switch mode := strings.ToUpper(fields[0]); mode {
+		case "IN", "OUT", "INOUT", "VARIADIC":
+			argument.mode = mode
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 1 && !multiwordTypes[strings.ToLower(fields[0])] {
+		argument.name = fields[0]
+		fields = fields[1:]
+	}
+	argument.typ = strings.Join(fields, " ")
+	return argument
+}
+
+// routineType normalizes the type of an argument or return value. Postgres
+// does not keep the type modifiers of either, so they are dropped.
+func routineType(dialect string, typ string) string {
+	if dialect == "postgres" {
+		buf := &strings.Builder{}
+		depth := 0
+		for _, r := range typ {
+			switch {
+			case r == '(':
+				depth++
+			case r == ')':
+				depth--
+			case depth == 0:
+				buf.WriteRune(r)
+			}
+		}
+		typ = buf.String()
+	}
+	return normalizeType(typ)
+}
+
+// routineSignature returns the normalized types of the input arguments of an
+// argument list, which is what Postgres uses to tell overloads apart.
+func routineSignature(arguments string) string {
+	var types []string
+	for _, arg := range splitArguments(arguments) {
+		argument := parseRoutineArgument(arg)
+		if argument.typ == "" || argument.mode == "OUT" {
+			continue
+		}
+		types = append(types, routineType("postgres", argument.typ))
+	}
+	return strings.Join(types, ",")
+}
+
+// routineArguments normalizes an argument list for comparison, keeping the
+// mode, name, type and default value of every argument.
+func routineArguments(dialect string, arguments string) string {
+	var args []string
+	for _, arg := range splitArguments(arguments) {
+		argument := parseRoutineArgument(arg)
+		if argument.mode == "IN" {
+			argument.mode = ""
+		}
+		args = append(args, strings.Join([]string{
+			argument.mode,
+			strings.ToLower(strings.Trim(argument.name, "\"`")),
+			routineType(dialect, argument.typ),
+			normalizeExpr(argument.defaultValue),
+		}, " "))
+	}
+	return strings.Join(args, ",")
+}
+
+// routineReturns normalizes the return type of a function for comparison.
+func routineReturns(dialect string, returns string) string {
+	returns = strings.TrimSpace(returns)
+	fields := splitFields(returns)
+	if len(fields) > 1 && strings.EqualFold(fields[0], "SETOF") {
+		return "setof " + routineReturns(dialect, strings.Join(fields[1:], " "))
+	}
+	if len(fields) == 1 && len(returns) > 5 && strings.EqualFold(returns[:5], "TABLE") && strings.HasSuffix(returns, ")") {
+		if i := strings.IndexByte(returns, '('); strings.TrimSpace(returns[5:i]) == "" {
+			return "table(" + routineArguments(dialect, returns[i+1:len(returns)-1]) + ")"
+		}
+	}
+	return routineType(dialect, returns)
+}
+
+// routineCharacteristics normalizes the characteristics of a routine for
+// comparison, filling in the defaults of the ones that were left out.
+// Characteristics that the database does not report back, like COST on
+// Postgres, are ignored.
+func routineCharacteristics(dialect string, characteristics string) string {
+	var values map[string]string
+	if dialect == "postgres" {
+		values = map[string]string{"language": "", "volatility": "volatile", "strict": "", "security": "invoker", "leakproof": "", "parallel": "unsafe"}
+	} else {
+		values = map[string]string{"deterministic": "", "sql": "contains", "security": "definer", "comment": "''"}
+	}
+	set := func(key, value string) {
+		if _, ok := values[key]; ok {
+			values[key] = value
+		}
+	}
+	tokens := exprTokens(characteristics)
+	for i := 0; i < len(tokens); i++ {
+		var next string
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+		switch tokens[i] {
+		case "language":
+			set(tokens[i], strings.ToLower(strings.Trim(next, "'\"")))
+			i++
+		case "security", "parallel", "comment":
+			set(tokens[i], next)
+			i++
+		case "immutable", "stable", "volatile":
+			set("volatility", tokens[i])
+		case "strict", "returns":
+			set("strict", "strict")
+		case "called":
+			set("strict", "")
+		case "leakproof", "deterministic":
+			set(tokens[i], tokens[i])
+		case "not":
+			set(next, "")
+			i++
+		case "contains", "no", "reads", "modifies":
+			set("sql", tokens[i])
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + values[key]
+	}
+	return strings.Join(keys, ",")
+}
+
+// routineEqual reports whether the routine in the database matches the
+// declared routine.
+func routineEqual(dialect string, gotRoutine, wantRoutine Routine) bool {
+	gotType, wantType := strings.ToUpper(gotRoutine.RoutineType), strings.ToUpper(wantRoutine.RoutineType)
+	if wantType == "" {
+		wantType = "FUNCTION"
+	}
+	if gotType != wantType {
+		return false
+	}
+	if wantType == "FUNCTION" && routineReturns(dialect, gotRoutine.Returns) != routineReturns(dialect, wantRoutine.Returns) {
+		return false
+	}
+	return routineArguments(dialect, gotRoutine.Arguments) == routineArguments(dialect, wantRoutine.Arguments) &&
+		routineCharacteristics(dialect, gotRoutine.Characteristics) == routineCharacteristics(dialect, wantRoutine.Characteristics) &&
+		normalizeRoutineBody(dialect, gotRoutine.Body) == normalizeRoutineBody(dialect, wantRoutine.Body)
+}
+
+// normalizeRoutineBody normalizes a routine body for comparison by dropping
+// comments and collapsing whitespace outside of string literals and quoted
+// identifiers. Anything else, like case and casts, is significant.
+func normalizeRoutineBody(dialect string, body string) string {
+	tokens, err := tokenizeDDL(dialect, body)
+	if err != nil {
+		return strings.TrimSpace(body)
+	}
+	buf := &strings.Builder{}
+	for i, token := range tokens {
+		if i > 0 && token.start > tokens[i-1].end {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(token.text)
+	}
+	return buf.String()
+}
+
+// GetRoutines returns the stored functions and procedures in the database,
+// keyed by their schema, name and (on Postgres) argument types.
+func GetRoutines(db DB, dialect string) (routines map[[3]string]Routine, err error) {
+	var query string
+	switch dialect {
+	case "postgres":
+		query = `SELECT n.nspname, p.proname
+	,CASE p.prokind WHEN 'p' THEN 'PROCEDURE' ELSE 'FUNCTION' END
+	,pg_get_function_arguments(p.oid)
+	,COALESCE(pg_get_function_result(p.oid), '')
+	,concat_ws(' ', 'LANGUAGE ' || l.lanname
+		,CASE p.provolatile WHEN 'i' THEN 'IMMUTABLE' WHEN 's' THEN 'STABLE' END
+		,CASE WHEN p.proisstrict THEN 'STRICT' END
+		,CASE WHEN p.prosecdef THEN 'SECURITY DEFINER' END
+		,CASE WHEN p.proleakproof THEN 'LEAKPROOF' END
+		,CASE p.proparallel WHEN 's' THEN 'PARALLEL SAFE' WHEN 'r' THEN 'PARALLEL RESTRICTED' END)
+	,p.prosrc
+FROM pg_proc AS p
+JOIN pg_namespace AS n ON n.oid = p.pronamespace
+JOIN pg_language AS l ON l.oid = p.prolang
+WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND p.prokind IN ('f', 'p')`
+	case "mysql":
+		query = `SELECT r.ROUTINE_SCHEMA, r.ROUTINE_NAME, r.ROUTINE_TYPE
+	,COALESCE((SELECT GROUP_CONCAT(CONCAT_WS(' ', IF(r.ROUTINE_TYPE = 'PROCEDURE', p.PARAMETER_MODE, NULL), p.PARAMETER_NAME, p.DTD_IDENTIFIER) ORDER BY p.ORDINAL_POSITION SEPARATOR ', ')
+		FROM information_schema.PARAMETERS AS p
+		WHERE p.SPECIFIC_SCHEMA = r.ROUTINE_SCHEMA AND p.SPECIFIC_NAME = r.SPECIFIC_NAME AND p.ORDINAL_POSITION > 0), '')
+	,IF(r.ROUTINE_TYPE = 'FUNCTION', r.DTD_IDENTIFIER, '')
+	,CONCAT_WS(' ', IF(r.IS_DETERMINISTIC = 'YES', 'DETERMINISTIC', NULL), r.SQL_DATA_ACCESS
+		,CONCAT('SQL SECURITY ', r.SECURITY_TYPE)
+		,IF(r.ROUTINE_COMMENT = '', NULL, CONCAT('COMMENT ', QUOTE(r.ROUTINE_COMMENT))))
+	,r.ROUTINE_DEFINITION
+FROM information_schema.ROUTINES AS r
+WHERE r.ROUTINE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')`
+	default:
+		return nil, fmt.Errorf("%s does not support stored routines", dialect)
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	routines = make(map[[3]string]Routine)
+	for rows.Next() {
+		var routine Routine
+		err = rows.Scan(&routine.RoutineSchema, &routine.RoutineName, &routine.RoutineType, &routine.Arguments, &routine.Returns, &routine.Characteristics, &routine.Body)
+		if err != nil {
+			return nil, err
+		}
+		routines[routineKey(dialect, routine)] = routine
+	}
+	return routines, rows.Err()
+}
+
+func routineKey(dialect string, routine Routine) [3]string {
+	if dialect != "postgres" {
+		return [3]string{routine.RoutineSchema, routine.RoutineName, ""}
+	}
+	return [3]string{routine.RoutineSchema, routine.RoutineName, routineSignature(routine.Arguments)}
+}
+
+// EnsureRoutines creates the routines that do not exist yet and replaces the
+// ones whose arguments, return type, characteristics or body have drifted
+// from the declared routine. MySQL has to drop the routine first, and so does
+// Postgres when the arguments or return type change because CREATE OR
+// REPLACE cannot change those.
+func EnsureRoutines(db DB, dialect string, routines []Routine) error {
+	if len(routines) == 0 {
+		return nil
+	}
+	gotRoutines, err := GetRoutines(db, dialect)
+	if err != nil {
+		return err
+	}
+	defaultSchema, err := DefaultSchema(db, dialect)
+	if err != nil {
+		return err
+	}
+	for _, routine := range routines {
+		key := routineKey(dialect, routine)
+		if key[0] == "" {
+			key[0] = defaultSchema
+		}
+		gotRoutine, ok := gotRoutines[key]
+		if ok && routineEqual(dialect, gotRoutine, routine) {
+			continue
+		}
+		var querylist []string
+		if ok {
+			name := qualifiedName(dialect, routine.RoutineSchema, routine.RoutineName)
+			switch {
+			case dialect == "mysql":
+				querylist = append(querylist, "DROP "+strings.ToUpper(gotRoutine.RoutineType)+" IF EXISTS "+name)
+			case routineArguments(dialect, gotRoutine.Arguments) != routineArguments(dialect, routine.Arguments) ||
+				routineReturns(dialect, gotRoutine.Returns) != routineReturns(dialect, routine.Returns):
+				querylist = append(querylist, "DROP "+strings.ToUpper(gotRoutine.RoutineType)+" IF EXISTS "+name+"("+key[2]+")")
+			}
+		}
+		query, err := createRoutineQuery(dialect, routine)
+		if err != nil {
+			return err
+		}
+		querylist = append(querylist, query)
+		for _, query := range querylist {
+			_, err = db.Exec(query)
+			if err != nil {
+				return fmt.Errorf("%s: %w", query, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestRoutineSignature(t *testing.T) {
+	assert := func(t *testing.T, arguments, wantSignature string) {
+		is := testutil.New(t)
+		is.Equal(wantSignature, routineSignature(arguments))
+	}
+	assert(t, "", "")
+	assert(t, "p_inventory_id INT", "integer")
+	assert(t, "p_film_id INT, p_store_id INT, OUT p_film_count INT", "integer,integer")
+	assert(t, "p_film_id integer, p_store_id integer", "integer,integer")
+	assert(t, "p_customer_id INT, p_effective_date TIMESTAMPTZ", "integer,timestamp with time zone")
+	assert(t, "p_customer_id integer, p_effective_date timestamp with time zone", "integer,timestamp with time zone")
+	assert(t, "INOUT amount NUMERIC(5, 2) DEFAULT 0, VARIADIC TEXT[]", "numeric,text[]")
+	assert(t, "NUMERIC(5, 2), VARCHAR (20)", "numeric,character varying")
+	assert(t, "amount numeric, name character varying", "numeric,character varying")
+}
+
+func TestRoutineEqual(t *testing.T) {
+	is := testutil.New(t)
+	want := Routine{
+		RoutineName:     "film_in_stock",
+		Arguments:       "p_film_id INT, p_store_id INT, OUT p_film_count INT",
+		Returns:         "SETOF INT",
+		Characteristics: "LANGUAGE sql STABLE",
+		Body:            "SELECT inventory_id FROM inventory WHERE film_id = $1 AND store_id = $2",
+	}
+	got := Routine{
+		RoutineSchema:   "public",
+		RoutineName:     "film_in_stock",
+		RoutineType:     "FUNCTION",
+		Arguments:       "p_film_id integer, p_store_id integer, OUT p_film_count integer",
+		Returns:         "SETOF integer",
+		Characteristics: "LANGUAGE sql STABLE PARALLEL UNSAFE",
+		Body:            "SELECT inventory_id FROM inventory WHERE film_id = $1 AND store_id = $2",
+	}
+	is.True(routineEqual("postgres", got, want))
+	changed := want
+	changed.Returns = "SETOF BIGINT"
+	is.True(!routineEqual("postgres", got, changed))
+	changed = want
+	changed.Arguments = "p_film_id INT, p_store_id INT DEFAULT 1, OUT p_film_count INT"
+	is.True(!routineEqual("postgres", got, changed))
+	changed = want
+	changed.Characteristics = "LANGUAGE sql STABLE STRICT"
+	is.True(!routineEqual("postgres", got, changed))
+	changed = want
+	changed.Characteristics = "LANGUAGE 'sql' STABLE"
+	is.True(routineEqual("postgres", got, changed))
+	changed = want
+	changed.Body = "SELECT inventory_id -- in stock\nFROM inventory\n/* of a store */ WHERE film_id = $1 AND store_id = $2"
+	is.True(routineEqual("postgres", got, changed))
+	changed.Body = "SELECT inventory_id FROM inventory WHERE film_id = $1::int AND store_id = $2"
+	is.True(!routineEqual("postgres", got, changed))
+	is.True(normalizeRoutineBody("postgres", "SELECT 'a  b'") != normalizeRoutineBody("postgres", "SELECT 'a b'"))
+	is.Equal("SELECT 'a  -- b'", normalizeRoutineBody("postgres", "SELECT\n 'a  -- b' -- c"))
+
+	want = Routine{
+		RoutineName:     "get_customer_balance",
+		Arguments:       "p_customer_id INT, p_effective_date DATETIME",
+		Returns:         "DECIMAL(5,2)",
+		Characteristics: "DETERMINISTIC READS SQL DATA",
+		Body:            "BEGIN RETURN 0; END",
+	}
+	got = Routine{
+		RoutineSchema:   "db",
+		RoutineName:     "get_customer_balance",
+		RoutineType:     "FUNCTION",
+		Arguments:       "p_customer_id int, p_effective_date datetime",
+		Returns:         "decimal(5,2)",
+		Characteristics: "DETERMINISTIC READS SQL DATA SQL SECURITY DEFINER",
+		Body:            "BEGIN RETURN 0; END",
+	}
+	is.True(routineEqual("mysql", got, want))
+	want.Characteristics = "READS SQL DATA"
+	is.True(!routineEqual("mysql", got, want))
+}
+
+func TestCreateRoutineQuery(t *testing.T) {
+	is := testutil.New(t)
+	lastDay := Routine{
+		RoutineName:     "last_day",
+		Arguments:       "TIMESTAMPTZ",
+		Returns:         "DATE",
+		Characteristics: "LANGUAGE sql IMMUTABLE STRICT",
+		Body:            " SELECT (date_trunc('month', $1) + INTERVAL '1 month' - INTERVAL '1 day')::DATE; ",
+	}
+	query, err := createRoutineQuery("postgres", lastDay)
+	is.NoErr(err)
+	is.Equal("CREATE OR REPLACE FUNCTION last_day(TIMESTAMPTZ) RETURNS DATE LANGUAGE sql IMMUTABLE STRICT AS $$ SELECT (date_trunc('month', $1) + INTERVAL '1 month' - INTERVAL '1 day')::DATE; $$", query)
+
+	rewardsReport := Routine{
+		RoutineName:     "rewards_report",
+		RoutineType:     "PROCEDURE",
+		Arguments:       "IN min_monthly_purchases TINYINT UNSIGNED, OUT count_rewardees INT",
+		Characteristics: "READS SQL DATA",
+		Body:            "BEGIN SET count_rewardees = 0; END",
+	}
+	query, err = createRoutineQuery("mysql", rewardsReport)
+	is.NoErr(err)
+	is.Equal("CREATE PROCEDURE rewards_report(IN min_monthly_purchases TINYINT UNSIGNED, OUT count_rewardees INT) READS SQL DATA BEGIN SET count_rewardees = 0; END", query)
+
+	_, err = createRoutineQuery("sqlite3", rewardsReport)
+	is.True(err != nil)
+	_, err = createRoutineQuery("postgres", Routine{RoutineName: "f", Body: "SELECT 1"})
+	is.True(err != nil)
+}
@@ -0,0 +1,91 @@
+package metadata
+
+import "fmt"
+
+// createSchemaQuery returns the query that creates a schema if it does not
+// exist yet. A MySQL schema is a database. SQLite has no schemas to create
+// (its closest equivalent, an attached database, is tied to a file that
+// has to be attached on every connection), so the query is empty.
+func createSchemaQuery(dialect string, schema string) string {
+	switch dialect {
+	case "postgres":
+		return "CREATE SCHEMA IF NOT EXISTS " + quoteIdentifier(dialect, schema)
+	case "mysql":
+		return "CREATE DATABASE IF NOT EXISTS " + quoteIdentifier(dialect, schema)
+	default:
+		return ""
+	}
+}
+
+// SearchPathQuery returns the query that makes unqualified table names
+// resolve to the given schemas, in order: SET search_path on Postgres and USE
+// on MySQL, which only takes a single database. SQLite always searches its
+// attached databases in order, so the query is empty.
+func SearchPathQuery(dialect string, schemas ...string) (string, error) {
+	if len(schemas) == 0 {
+		return "", fmt.Errorf("no schemas provided")
+	}
+	switch dialect {
+	case "postgres":
+		return "SET search_path TO " + quoteIdentifiers(dialect, schemas), nil
+	case "mysql":
+		if len(schemas) > 1 {
+			return "", fmt.Errorf("mysql: cannot search more than one database")
+		}
+		return "USE " + quoteIdentifier(dialect, schemas[0]), nil
+	case "sqlite3":
+		return "", nil
+	default:
+		return "", fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+// DefaultSchema returns the schema that unqualified table names resolve to:
+// the first existing schema in the search_path on Postgres, the current
+// database on MySQL and main on SQLite.
+func DefaultSchema(db DB, dialect string) (string, error) {
+	var query string
+	switch dialect {
+	case "postgres":
+		query = "SELECT COALESCE(current_schema(), '')"
+	case "mysql":
+		query = "SELECT COALESCE(DATABASE(), '')"
+	case "sqlite3":
+		return "main", nil
+	default:
+		return "", fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	var schema string
+	err := db.QueryRow(query).Scan(&schema)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", query, err)
+	}
+	return schema, nil
+}
+
+// EnsureSchemas creates every schema named by the tables in wantTables that
+// does not exist yet. Tables without a schema belong to the default schema,
+// which is assumed to exist.
+func EnsureSchemas(db DB, dialect string, wantTables WantTables) error {
+	tableNames, err := wantTables.GetTables()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, tableName := range tableNames {
+		schema := tableName[0]
+		if schema == "" || seen[schema] {
+			continue
+		}
+		seen[schema] = true
+		query := createSchemaQuery(dialect, schema)
+		if query == "" {
+			continue
+		}
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
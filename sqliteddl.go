@@ -0,0 +1,24 @@
+package metadata
+
+// sqliteTokenize splits an SQLite statement into tokens.
+func sqliteTokenize(query string) ([]ddlToken, error) {
+	return tokenizeDDL("sqlite3", query)
+}
+
+// ParseSQLiteCreateTable parses an SQLite CREATE TABLE statement into its
+// table name, columns and table constraints. Everything SQLite does not
+// report through its pragmas (generated columns, collations, CHECK
+// constraints and constraint names) is taken from the statement. Constraints
+// that were not named are named following Postgres' naming convention, and
+// CHECK constraints declared on a column are returned as table constraints.
+func ParseSQLiteCreateTable(query string) (tableName [2]string, columns []Column, constraints []TableConstraint, err error) {
+	tokens, err := sqliteTokenize(query)
+	if err != nil {
+		return tableName, nil, nil, err
+	}
+	table, err := parseCreateTable("sqlite3", query, tokens)
+	if err != nil {
+		return tableName, nil, nil, err
+	}
+	return table.tableName, table.columns, table.constraints, nil
+}
@@ -0,0 +1,138 @@
+package metadata
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestSQLiteTokenize(t *testing.T) {
+	is := testutil.New(t)
+	tokens, err := sqliteTokenize(`CREATE TABLE "a ""b""" ([c d] TEXT, ` + "`e`" + ` INT DEFAULT -1.5, -- ) comment
+	f BLOB DEFAULT x'00' /* ( */ CHECK (f <> 'it''s'))`)
+	is.NoErr(err)
+	var texts []string
+	for _, token := range tokens {
+		texts = append(texts, token.text)
+	}
+	is.Equal([]string{
+		"CREATE", "TABLE", `"a ""b"""`, "(", "[c d]", "TEXT", ",", "`e`", "INT", "DEFAULT", "-", "1.5", ",",
+		"f", "BLOB", "DEFAULT", "x'00'", "CHECK", "(", "f", "<>", "'it''s'", ")", ")",
+	}, texts)
+	_, err = sqliteTokenize("CREATE TABLE t (a TEXT DEFAULT 'unterminated)")
+	is.True(err != nil)
+}
+
+func TestParseSQLiteCreateTable(t *testing.T) {
+	is := testutil.New(t)
+	b, err := os.ReadFile("sq-tables.sql")
+	is.NoErr(err)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	parsed := make(map[string][]Column)
+	checks := make(map[string][]TableConstraint)
+	for _, statement := range strings.Split(string(b), ";\n") {
+		statement = strings.TrimSpace(statement)
+		if !strings.HasPrefix(statement, "CREATE TABLE") {
+			continue
+		}
+		tableName, columns, constraints, err := ParseSQLiteCreateTable(statement)
+		is.NoErr(err)
+		parsed[tableName[1]] = columns
+		_, err = db.Exec(statement)
+		is.NoErr(err)
+
+		// Cross-check against what SQLite reports about the table.
+		rows, err := db.Query("SELECT name, type, \"notnull\", pk FROM pragma_table_xinfo(?) ORDER BY cid", tableName[1])
+		is.NoErr(err)
+		var i, pkCount int
+		for rows.Next() {
+			var name, typ string
+			var notnull bool
+			var pk int
+			is.NoErr(rows.Scan(&name, &typ, &notnull, &pk))
+			is.Equal(name, columns[i].ColumnName)
+			// SQLite reports the type of a generated column with GENERATED ALWAYS tacked on.
+			is.Equal(strings.TrimSuffix(typ, " GENERATED ALWAYS"), columns[i].ColumnType)
+			is.Equal(notnull, columns[i].IsNotNull)
+			if pk > 0 {
+				pkCount++
+			}
+			i++
+		}
+		rows.Close()
+		is.Equal(len(columns), i)
+		var foreignKeys, wantForeignKeys int
+		is.NoErr(db.QueryRow("SELECT COUNT(DISTINCT id) FROM pragma_foreign_key_list(?)", tableName[1]).Scan(&wantForeignKeys))
+		var primaryKeyColumns int
+		for _, column := range columns {
+			if column.IsPrimaryKey {
+				primaryKeyColumns++
+			}
+			if column.ReferencesTable.Valid {
+				foreignKeys++
+			}
+		}
+		for _, constraint := range constraints {
+			switch constraint.ConstraintType {
+			case "FOREIGN KEY":
+				foreignKeys++
+			case "PRIMARY KEY":
+				primaryKeyColumns += len(constraint.Columns)
+			case "CHECK":
+				checks[tableName[1]] = append(checks[tableName[1]], constraint)
+			}
+		}
+		is.Equal(wantForeignKeys, foreignKeys)
+		is.Equal(pkCount, primaryKeyColumns)
+	}
+	is.Equal(16, len(parsed))
+
+	actor := parsed["actor"]
+	is.Equal("first_name || ' ' || last_name", actor[3].GeneratedExpr.String)
+	is.True(!actor[3].GeneratedStored)
+	is.True(actor[4].GeneratedStored)
+	is.Equal("DATETIME('now')", actor[5].ColumnDefault.String)
+	film := parsed["film"]
+	is.Equal("DECIMAL(4,2)", film[7].ColumnType)
+	is.Equal("4.99", film[7].ColumnDefault.String)
+	is.Equal("'G'", film[10].ColumnDefault.String)
+	is.Equal("rating IN ('G','PG','PG-13','R','NC-17')", checks["film"][1].CheckExpr.String)
+	dummy := parsed["dummy_table"]
+	is.Equal(sql.NullString{String: "NOCASE", Valid: true}, dummy[3].Collation)
+	is.Equal("'red'", dummy[3].ColumnDefault.String)
+	is.Equal([]string{"dummy_table_score_positive_check", "dummy_table_score_id1_greater_than_check"}, []string{
+		checks["dummy_table"][0].ConstraintName, checks["dummy_table"][1].ConstraintName,
+	})
+}
+
+func TestParseSQLiteCreateTableNames(t *testing.T) {
+	is := testutil.New(t)
+	tableName, columns, constraints, err := ParseSQLiteCreateTable(`CREATE TEMP TABLE IF NOT EXISTS "main".[order] (
+    "id" INTEGER PRIMARY KEY DESC ON CONFLICT REPLACE AUTOINCREMENT
+    ,` + "`parent id`" + ` INT CONSTRAINT parent_fk REFERENCES "order" (id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED
+    ,qty INT CHECK (qty > 0) CHECK (qty < 100)
+    ,CHECK (qty <> 13)
+    ,UNIQUE (qty COLLATE NOCASE DESC, "parent id")
+)`)
+	is.NoErr(err)
+	is.Equal([2]string{"main", "order"}, tableName)
+	is.Equal("parent id", columns[1].ColumnName)
+	is.True(!columns[1].ReferencesTable.Valid)
+	is.Equal("parent_fk", constraints[0].ConstraintName)
+	is.Equal("FOREIGN KEY", constraints[0].ConstraintType)
+	is.Equal([]string{"parent id"}, constraints[0].Columns)
+	is.Equal("order", constraints[0].ReferencesTable)
+	is.Equal([]string{"id"}, constraints[0].ReferencesColumns)
+	is.Equal(sql.NullString{String: "SET NULL", Valid: true}, constraints[0].OnDelete)
+	var names []string
+	for _, constraint := range constraints {
+		names = append(names, constraint.ConstraintName)
+	}
+	is.Equal([]string{"parent_fk", "order_qty_check", "order_qty_check1", "order_check", "order_qty_parent id_key"}, names)
+}
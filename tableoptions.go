@@ -0,0 +1,177 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TableOptions are the MySQL table options that follow CREATE TABLE (...).
+type TableOptions struct {
+	Engine        string
+	Charset       string
+	Collation     string
+	RowFormat     string
+	AutoIncrement int64 // starting value, only applied when creating the table
+}
+
+// tableOptionsFromModifier parses the value of a create tag modifier, as in
+// create={ENGINE=InnoDB DEFAULT CHARSET=utf8}. The = is optional and DEFAULT
+// is ignored, as in MySQL.
+func tableOptionsFromModifier(value string) (TableOptions, error) {
+	var opts TableOptions
+	tokens := strings.Fields(strings.ReplaceAll(value, "=", " = "))
+	for i := 0; i < len(tokens); i++ {
+		key := strings.ToUpper(tokens[i])
+		if key == "DEFAULT" {
+			continue
+		}
+		if key == "CHARACTER" && i+1 < len(tokens) && strings.EqualFold(tokens[i+1], "SET") {
+			key = "CHARSET"
+			i++
+		}
+		if i+1 < len(tokens) && tokens[i+1] == "=" {
+			i++
+		}
+		if i+1 >= len(tokens) {
+			return opts, fmt.Errorf("create: %s has no value", key)
+		}
+		i++
+		val := tokens[i]
+		switch key {
+		case "ENGINE":
+			opts.Engine = val
+		case "CHARSET":
+			opts.Charset = strings.ToLower(val)
+		case "COLLATE":
+			opts.Collation = strings.ToLower(val)
+		case "ROW_FORMAT":
+			opts.RowFormat = strings.ToUpper(val)
+		case "AUTO_INCREMENT":
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("create: invalid AUTO_INCREMENT %s", val)
+			}
+			opts.AutoIncrement = n
+		default:
+			return opts, fmt.Errorf("create: unknown table option %s", key)
+		}
+	}
+	return opts, nil
+}
+
+// tableOptionsClause returns the table options to append to a MySQL CREATE
+// TABLE.
+func tableOptionsClause(opts TableOptions) string {
+	var clauses []string
+	if opts.Engine != "" {
+		clauses = append(clauses, "ENGINE="+opts.Engine)
+	}
+	if opts.Charset != "" {
+		clauses = append(clauses, "DEFAULT CHARSET="+opts.Charset)
+	}
+	if opts.Collation != "" {
+		clauses = append(clauses, "COLLATE="+opts.Collation)
+	}
+	if opts.RowFormat != "" {
+		clauses = append(clauses, "ROW_FORMAT="+opts.RowFormat)
+	}
+	if opts.AutoIncrement > 0 {
+		clauses = append(clauses, "AUTO_INCREMENT="+strconv.FormatInt(opts.AutoIncrement, 10))
+	}
+	return strings.Join(clauses, " ")
+}
+
+// GetTableOptions returns the options of a MySQL table. AutoIncrement is the
+// table's next AUTO_INCREMENT value.
+func GetTableOptions(db DB, tableName [2]string) (TableOptions, error) {
+	const query = `SELECT COALESCE(t.ENGINE, ''), COALESCE(c.CHARACTER_SET_NAME, ''), COALESCE(t.TABLE_COLLATION, ''), COALESCE(t.ROW_FORMAT, ''), COALESCE(t.AUTO_INCREMENT, 0)
+FROM information_schema.TABLES AS t
+LEFT JOIN information_schema.COLLATION_CHARACTER_SET_APPLICABILITY AS c ON c.COLLATION_NAME = t.TABLE_COLLATION
+WHERE t.TABLE_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND t.TABLE_NAME = ?`
+	var opts TableOptions
+	err := db.QueryRow(query, tableName[0], tableName[1]).Scan(&opts.Engine, &opts.Charset, &opts.Collation, &opts.RowFormat, &opts.AutoIncrement)
+	if err != nil {
+		return opts, fmt.Errorf("%s: %w", query, err)
+	}
+	opts.RowFormat = strings.ToUpper(opts.RowFormat)
+	return opts, nil
+}
+
+// normalizeCharset maps utf8 to utf8mb3, the name MySQL 8 reports it as.
+func normalizeCharset(charset string) string {
+	charset = strings.ToLower(charset)
+	if charset == "utf8" {
+		return "utf8mb3"
+	}
+	return charset
+}
+
+func normalizeCollation(collation string) string {
+	collation = strings.ToLower(collation)
+	if strings.HasPrefix(collation, "utf8_") {
+		return "utf8mb3_" + strings.TrimPrefix(collation, "utf8_")
+	}
+	return collation
+}
+
+// TableOptionsMismatches returns which of the wanted table options differ from
+// the table's: engine, charset, collation or row_format. Options that are not
+// wanted are not compared, and neither is AUTO_INCREMENT.
+func TableOptionsMismatches(got, want TableOptions) (mismatches []string) {
+	if want.Engine != "" && !strings.EqualFold(got.Engine, want.Engine) {
+		mismatches = append(mismatches, "engine")
+	}
+	if want.Charset != "" && normalizeCharset(got.Charset) != normalizeCharset(want.Charset) {
+		mismatches = append(mismatches, "charset")
+	}
+	if want.Collation != "" && normalizeCollation(got.Collation) != normalizeCollation(want.Collation) {
+		mismatches = append(mismatches, "collation")
+	}
+	if want.RowFormat != "" && !strings.EqualFold(got.RowFormat, want.RowFormat) {
+		mismatches = append(mismatches, "row_format")
+	}
+	return mismatches
+}
+
+// AlterTableOptionsQuery returns the ALTER TABLE query that brings a table's
+// options in line with the wanted ones, or an empty query if they already
+// match. Changing the default charset only affects columns added later; if
+// convert is true the charset is changed with CONVERT TO CHARACTER SET
+// instead, which rewrites every existing text column (and the whole table).
+func AlterTableOptionsQuery(tableName [2]string, got, want TableOptions, convert bool) string {
+	var clauses []string
+	converted := false
+	for _, mismatch := range TableOptionsMismatches(got, want) {
+		switch mismatch {
+		case "engine":
+			clauses = append(clauses, "ENGINE="+want.Engine)
+		case "charset", "collation":
+			if convert {
+				if converted {
+					continue
+				}
+				converted = true
+				charset := want.Charset
+				if charset == "" {
+					charset = got.Charset
+				}
+				clause := "CONVERT TO CHARACTER SET " + charset
+				if want.Collation != "" {
+					clause += " COLLATE " + want.Collation
+				}
+				clauses = append(clauses, clause)
+			} else if mismatch == "charset" {
+				clauses = append(clauses, "DEFAULT CHARSET="+want.Charset)
+			} else {
+				clauses = append(clauses, "COLLATE="+want.Collation)
+			}
+		case "row_format":
+			clauses = append(clauses, "ROW_FORMAT="+want.RowFormat)
+		}
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+	return "ALTER TABLE " + qualifiedName("mysql", tableName[0], tableName[1]) + " " + strings.Join(clauses, ", ")
+}
@@ -0,0 +1,27 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestTableOptions(t *testing.T) {
+	is := testutil.New(t)
+	want, err := tableOptionsFromModifier("ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE utf8mb4_0900_ai_ci row_format=dynamic AUTO_INCREMENT=1000")
+	is.NoErr(err)
+	is.Equal(TableOptions{Engine: "InnoDB", Charset: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", RowFormat: "DYNAMIC", AutoIncrement: 1000}, want)
+	is.Equal("ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci ROW_FORMAT=DYNAMIC AUTO_INCREMENT=1000", tableOptionsClause(want))
+	_, err = tableOptionsFromModifier("ENGINE")
+	is.True(err != nil)
+
+	opts, err := tableOptionsFromModifier("ENGINE=InnoDB DEFAULT CHARACTER SET = utf8")
+	is.NoErr(err)
+	is.Equal(0, len(TableOptionsMismatches(TableOptions{Engine: "InnoDB", Charset: "utf8mb3"}, opts)))
+
+	latin1 := TableOptions{Engine: "InnoDB", Charset: "latin1", Collation: "latin1_swedish_ci", RowFormat: "Dynamic", AutoIncrement: 52}
+	is.Equal([]string{"charset", "collation"}, TableOptionsMismatches(latin1, want))
+	is.Equal("ALTER TABLE address DEFAULT CHARSET=utf8mb4, COLLATE=utf8mb4_0900_ai_ci", AlterTableOptionsQuery([2]string{"", "address"}, latin1, want, false))
+	is.Equal("ALTER TABLE address CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci", AlterTableOptionsQuery([2]string{"", "address"}, latin1, want, true))
+	is.Equal("", AlterTableOptionsQuery([2]string{"", "address"}, want, want, true))
+}
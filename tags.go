@@ -0,0 +1,357 @@
+package metadata
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var refOptions = map[string]RefOption{
+	"noaction":   NoAction,
+	"cascade":    Cascade,
+	"restrict":   Restrict,
+	"setnull":    SetNull,
+	"setdefault": SetDefault,
+}
+
+// tableConstraintFromModifier parses a table-level tag modifier such as
+// primarykey={. cols=id1,id2} or
+// foreignkey={. cols=film_id,actor_id references={film_actor cols=film_id,actor_id} ondelete=cascade}
+// into a TableConstraint. A name of "." is replaced by the name the dialect
+// would have given the constraint, see defaultConstraintName.
+func tableConstraintFromModifier(dialect string, tableName [2]string, modifier [2]string) (TableConstraint, error) {
+	constraint := TableConstraint{TableSchema: tableName[0], TableName: tableName[1]}
+	switch modifier[0] {
+	case "primarykey":
+		constraint.ConstraintType = "PRIMARY KEY"
+	case "unique":
+		constraint.ConstraintType = "UNIQUE"
+	case "foreignkey":
+		constraint.ConstraintType = "FOREIGN KEY"
+	default:
+		return constraint, fmt.Errorf("%s is not a table constraint", modifier[0])
+	}
+	name, submodifiers, err := lexValue(modifier[1])
+	if err != nil {
+		return constraint, err
+	}
+	for _, submodifier := range submodifiers {
+		switch submodifier[0] {
+		case "cols":
+			constraint.Columns = strings.Split(submodifier[1], ",")
+		case "references":
+			if constraint.ConstraintType != "FOREIGN KEY" {
+				return constraint, fmt.Errorf("%s: references is only valid for a foreignkey", modifier[0])
+			}
+			refTable, refModifiers, err := lexValue(submodifier[1])
+			if err != nil {
+				return constraint, err
+			}
+			if i := strings.Index(refTable, "."); i >= 0 {
+				constraint.ReferencesSchema, constraint.ReferencesTable = refTable[:i], refTable[i+1:]
+			} else {
+				constraint.ReferencesTable = refTable
+			}
+			for _, refModifier := range refModifiers {
+				if refModifier[0] != "cols" {
+					return constraint, fmt.Errorf("references: unknown modifier %s", refModifier[0])
+				}
+				constraint.ReferencesColumns = strings.Split(refModifier[1], ",")
+			}
+		case "onupdate", "ondelete":
+			if constraint.ConstraintType != "FOREIGN KEY" {
+				return constraint, fmt.Errorf("%s: %s is only valid for a foreignkey", modifier[0], submodifier[0])
+			}
+			opt, ok := refOptions[submodifier[1]]
+			if !ok {
+				return constraint, fmt.Errorf("%s: invalid option %s", submodifier[0], submodifier[1])
+			}
+			if submodifier[0] == "onupdate" {
+				constraint.OnUpdate = sql.NullString{String: string(opt), Valid: true}
+			} else {
+				constraint.OnDelete = sql.NullString{String: string(opt), Valid: true}
+			}
+		default:
+			return constraint, fmt.Errorf("%s: unknown modifier %s", modifier[0], submodifier[0])
+		}
+	}
+	if len(constraint.Columns) == 0 {
+		return constraint, fmt.Errorf("%s: no cols provided", modifier[0])
+	}
+	if constraint.ConstraintType == "FOREIGN KEY" {
+		if constraint.ReferencesTable == "" {
+			return constraint, fmt.Errorf("foreignkey: no references provided")
+		}
+		if len(constraint.ReferencesColumns) > 0 && len(constraint.ReferencesColumns) != len(constraint.Columns) {
+			return constraint, fmt.Errorf("foreignkey: %d cols but %d referenced cols", len(constraint.Columns), len(constraint.ReferencesColumns))
+		}
+	}
+	if name == "." || name == "" {
+		name = defaultConstraintName(dialect, tableName[1], constraint.ConstraintType, constraint.Columns)
+	}
+	constraint.ConstraintName = name
+	return constraint, nil
+}
+
+// defaultConstraintName returns the name a dialect gives a constraint that
+// was declared without one. Postgres names a primary key {table}_pkey and
+// MySQL always names it PRIMARY. MySQL names a unique key after its first
+// column. Everything else follows Postgres' {table}_{columns}_{suffix}
+// convention, which SQLite (having no constraint names) borrows.
+func defaultConstraintName(dialect string, tableName string, constraintType string, columns []string) string {
+	switch constraintType {
+	case "PRIMARY KEY":
+		if dialect == "mysql" {
+			return "PRIMARY"
+		}
+		return tableName + "_pkey"
+	case "UNIQUE":
+		if dialect == "mysql" && len(columns) > 0 {
+			return columns[0]
+		}
+		return tableName + "_" + strings.Join(columns, "_") + "_key"
+	case "FOREIGN KEY":
+		return tableName + "_" + strings.Join(columns, "_") + "_fkey"
+	default:
+		if len(columns) == 0 {
+			return tableName + "_check"
+		}
+		return tableName + "_" + strings.Join(columns, "_") + "_check"
+	}
+}
+
+// indexPart is an index declared by a column's (or a table's) index tag
+// modifier. Column index modifiers that share the same id, like index={1}
+// and index={1 order=2}, are key parts of the same index and are merged
+// together by indexesFromParts.
+type indexPart struct {
+	id    string
+	order int
+	names []string // column names used to generate the index name
+	index Index
+	// key part options of a column index
+	direction, nullsOrder, opclass, collation string
+	prefixLength                              int
+}
+
+func isIndexID(s string) bool {
+	if s == "" || s == "." {
+		return true
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// indexPartFromModifier parses the value of an index tag modifier such as
+// {1 order=2 expr={CAST(JSON_EXTRACT(data, '$.age') AS INT)}} (declared on
+// a column) or {. cols=store_id,film_id unique} (declared on a table, in
+// which case columnName is empty). Instead of an id, the value may also be an
+// index type, as in index=gin. An index is named after its table and columns
+// unless it is given a name, as in index={1 name=actor_name_idx}.
+func indexPartFromModifier(dialect string, tableName [2]string, columnName string, value string) (indexPart, error) {
+	part := indexPart{index: Index{TableSchema: tableName[0], TableName: tableName[1]}}
+	id, modifiers, err := lexValue(value)
+	if err != nil {
+		return part, err
+	}
+	if isIndexID(id) {
+		part.id = id
+	} else {
+		// index=btree,text_pattern_ops is an index type followed by an
+		// operator class.
+		indexType := id
+		if i := strings.Index(id, ","); i >= 0 {
+			indexType, part.opclass = id[:i], id[i+1:]
+		}
+		_, err = dialectIndexType(dialect, indexType)
+		if err != nil {
+			return part, fmt.Errorf("index: %w", err)
+		}
+		part.index.IndexType = strings.ToUpper(indexType)
+	}
+	var expr string
+	for _, modifier := range modifiers {
+		switch modifier[0] {
+		case "name":
+			part.index.IndexName = modifier[1]
+		case "unique":
+			part.index.IsUnique = true
+		case "online":
+			part.index.Online = true
+		case "where":
+			part.index.IsPartial = true
+			part.index.Where = modifier[1]
+		case "expr":
+			expr = modifier[1]
+		case "cols":
+			if columnName != "" {
+				return part, fmt.Errorf("index: cols is only valid for a table index")
+			}
+			part.names = strings.Split(modifier[1], ",")
+			part.index.Columns = part.names
+			part.index.Exprs = make([]string, len(part.names))
+		case "order":
+			part.order, err = strconv.Atoi(modifier[1])
+			if err != nil || part.order < 1 {
+				return part, fmt.Errorf("index: invalid order %s", modifier[1])
+			}
+		case "type":
+			_, err = dialectIndexType(dialect, modifier[1])
+			if err != nil {
+				return part, fmt.Errorf("index: %w", err)
+			}
+			part.index.IndexType = strings.ToUpper(modifier[1])
+		case "include":
+			part.index.Include = strings.Split(modifier[1], ",")
+		case "asc", "desc":
+			part.direction = strings.ToUpper(modifier[0])
+		case "nulls":
+			switch strings.ToLower(modifier[1]) {
+			case "first", "last":
+				part.nullsOrder = "NULLS " + strings.ToUpper(modifier[1])
+			default:
+				return part, fmt.Errorf("index: invalid nulls %s", modifier[1])
+			}
+		case "opclass":
+			part.opclass = modifier[1]
+		case "collate":
+			part.collation = modifier[1]
+		case "prefix":
+			part.prefixLength, err = strconv.Atoi(modifier[1])
+			if err != nil || part.prefixLength < 1 {
+				return part, fmt.Errorf("index: invalid prefix %s", modifier[1])
+			}
+		default:
+			return part, fmt.Errorf("index: unknown modifier %s", modifier[0])
+		}
+	}
+	if columnName == "" {
+		if len(part.names) == 0 {
+			return part, fmt.Errorf("index: no cols provided")
+		}
+		if expr != "" || part.order != 0 || part.direction != "" || part.nullsOrder != "" || part.opclass != "" || part.collation != "" || part.prefixLength != 0 {
+			return part, fmt.Errorf("index: expr, order and key part options are only valid for a column index")
+		}
+		if part.id == "" {
+			part.id = "."
+		}
+		return part, nil
+	}
+	part.names = []string{columnName}
+	if expr != "" {
+		part.index.Columns, part.index.Exprs = []string{""}, []string{expr}
+	} else {
+		part.index.Columns, part.index.Exprs = []string{columnName}, []string{""}
+	}
+	return part, nil
+}
+
+// indexesFromParts merges the index parts that share the same numeric id
+// into a single index, ordering their key parts by their order. Key parts
+// without an order fill the remaining positions in declaration order.
+// Indexes that were not given a name are named after their table and
+// columns, following Postgres' naming convention. Each index is validated
+// against the dialect.
+func indexesFromParts(dialect string, parts []indexPart) ([]Index, error) {
+	var ids []string
+	groups := make(map[string][]indexPart)
+	for i, part := range parts {
+		id := part.id
+		if id == "" || id == "." {
+			id = "." + strconv.Itoa(i)
+		}
+		if _, ok := groups[id]; !ok {
+			ids = append(ids, id)
+		}
+		groups[id] = append(groups[id], part)
+	}
+	var indexes []Index
+	for _, id := range ids {
+		group := groups[id]
+		taken := make(map[int]bool)
+		for _, part := range group {
+			taken[part.order] = true
+		}
+		next := 1
+		for i := range group {
+			if group[i].order != 0 {
+				continue
+			}
+			for taken[next] {
+				next++
+			}
+			group[i].order, taken[next] = next, true
+		}
+		sort.SliceStable(group, func(i, j int) bool { return group[i].order < group[j].order })
+		index := group[0].index
+		index.Columns = nil
+		index.Exprs = nil
+		index.Include = nil
+		var names []string
+		for _, part := range group {
+			if part.index.IndexName != "" {
+				if index.IndexName != "" && index.IndexName != part.index.IndexName {
+					return nil, fmt.Errorf("index %s: conflicting names %s and %s", id, index.IndexName, part.index.IndexName)
+				}
+				index.IndexName = part.index.IndexName
+			}
+			if part.index.IsUnique {
+				index.IsUnique = true
+			}
+			if part.index.Online {
+				index.Online = true
+			}
+			if part.index.Where != "" {
+				if index.Where != "" && index.Where != part.index.Where {
+					return nil, fmt.Errorf("index %s: conflicting where clauses", id)
+				}
+				index.IsPartial, index.Where = true, part.index.Where
+			}
+			if part.index.IndexType != "" {
+				if index.IndexType != "" && index.IndexType != part.index.IndexType {
+					return nil, fmt.Errorf("index %s: conflicting index types %s and %s", id, index.IndexType, part.index.IndexType)
+				}
+				index.IndexType = part.index.IndexType
+			}
+			for _, name := range part.index.Include {
+				if !containsName(index.Include, name) {
+					index.Include = append(index.Include, name)
+				}
+			}
+			for j := range part.index.Columns {
+				index.Columns = append(index.Columns, part.index.Columns[j])
+				index.Exprs = append(index.Exprs, part.index.Exprs[j])
+				index.Directions = append(index.Directions, part.direction)
+				index.NullsOrders = append(index.NullsOrders, part.nullsOrder)
+				index.Opclasses = append(index.Opclasses, part.opclass)
+				index.Collations = append(index.Collations, part.collation)
+				index.PrefixLengths = append(index.PrefixLengths, part.prefixLength)
+			}
+			names = append(names, part.names...)
+		}
+		if index.IndexName == "" {
+			index.IndexName = index.TableName + "_" + strings.Join(names, "_") + "_idx"
+		}
+		err := ValidateIndex(dialect, index)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
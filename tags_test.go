@@ -0,0 +1,79 @@
+package metadata
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestTableConstraintFromModifier(t *testing.T) {
+	assert := func(t *testing.T, dialect string, tableName [2]string, modifier [2]string, wantConstraint TableConstraint) {
+		is := testutil.New(t)
+		gotConstraint, err := tableConstraintFromModifier(dialect, tableName, modifier)
+		is.NoErr(err)
+		is.Equal(wantConstraint, gotConstraint)
+	}
+	filmActor := [2]string{"", "film_actor"}
+	assert(t, "postgres", filmActor, [2]string{"primarykey", ". cols=actor_id,film_id"}, TableConstraint{
+		TableName:      "film_actor",
+		ConstraintName: "film_actor_pkey",
+		ConstraintType: "PRIMARY KEY",
+		Columns:        []string{"actor_id", "film_id"},
+	})
+	assert(t, "postgres", [2]string{"", "rental_actor"}, [2]string{"foreignkey", "rental_actor_fkey cols=actor_id,film_id references={public.film_actor cols=actor_id,film_id} ondelete=cascade"}, TableConstraint{
+		TableName:         "rental_actor",
+		ConstraintName:    "rental_actor_fkey",
+		ConstraintType:    "FOREIGN KEY",
+		Columns:           []string{"actor_id", "film_id"},
+		ReferencesSchema:  "public",
+		ReferencesTable:   "film_actor",
+		ReferencesColumns: []string{"actor_id", "film_id"},
+		OnDelete:          sql.NullString{String: "CASCADE", Valid: true},
+	})
+	assert(t, "mysql", filmActor, [2]string{"primarykey", ". cols=actor_id,film_id"}, TableConstraint{
+		TableName:      "film_actor",
+		ConstraintName: "PRIMARY",
+		ConstraintType: "PRIMARY KEY",
+		Columns:        []string{"actor_id", "film_id"},
+	})
+	assert(t, "mysql", [2]string{"", "customer"}, [2]string{"unique", ". cols=email,first_name"}, TableConstraint{
+		TableName:      "customer",
+		ConstraintName: "email",
+		ConstraintType: "UNIQUE",
+		Columns:        []string{"email", "first_name"},
+	})
+	is := testutil.New(t)
+	definition, err := constraintDefinition("mysql", TableConstraint{ConstraintName: "PRIMARY", ConstraintType: "PRIMARY KEY", Columns: []string{"actor_id", "film_id"}})
+	is.NoErr(err)
+	is.Equal("PRIMARY KEY (actor_id, film_id)", definition)
+	is.Equal("", referencesSchema([2]string{"", "film_actor"}, "public", "public"))
+	is.Equal("other", referencesSchema([2]string{"", "film_actor"}, "public", "other"))
+	_, err = tableConstraintFromModifier("postgres", filmActor, [2]string{"primarykey", "."})
+	is.True(err != nil)
+	_, err = tableConstraintFromModifier("postgres", filmActor, [2]string{"foreignkey", ". cols=a,b references={t cols=x}"})
+	is.True(err != nil)
+	_, err = tableConstraintFromModifier("postgres", filmActor, [2]string{"unique", ". cols=a ondelete=cascade"})
+	is.True(err != nil)
+}
+
+func TestCompositeKeyFixture(t *testing.T) {
+	is := testutil.New(t)
+	field, _ := reflect.TypeOf(_FILM_ACTOR_CREDIT{}).FieldByName("tableinfo")
+	modifiers, err := lexModifiers(field.Tag.Get("ddl"))
+	is.NoErr(err)
+	tableName := [2]string{"", "film_actor_credit"}
+	var constraints []TableConstraint
+	for _, modifier := range modifiers[1:] {
+		constraint, err := tableConstraintFromModifier("postgres", tableName, modifier)
+		is.NoErr(err)
+		constraints = append(constraints, constraint)
+	}
+	is.Equal(2, len(constraints))
+	is.Equal("film_actor_credit_pkey", constraints[0].ConstraintName)
+	is.Equal([]string{"actor_id", "film_id", "credit_order"}, constraints[0].Columns)
+	is.Equal("film_actor_credit_actor_id_film_id_fkey", constraints[1].ConstraintName)
+	is.Equal([]string{"actor_id", "film_id"}, constraints[1].ReferencesColumns)
+	is.Equal(sql.NullString{String: "CASCADE", Valid: true}, constraints[1].OnDelete)
+}
@@ -0,0 +1,204 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TenantOptions configures EnsureTenants.
+type TenantOptions struct {
+	// Concurrency is the maximum number of tenants ensured at once. It
+	// defaults to 1.
+	Concurrency int
+	// FailFast stops EnsureTenants from starting on any more tenants once one
+	// has failed. The tenants it did not start on are reported as skipped.
+	FailFast bool
+}
+
+// TenantResult is the outcome of ensuring the tables of a single tenant.
+type TenantResult struct {
+	Tenant  string
+	Err     error
+	Skipped bool
+}
+
+// TenantsError is returned by EnsureTenants when any tenant failed.
+type TenantsError struct {
+	Failed []TenantResult
+	Total  int
+}
+
+func (e *TenantsError) Error() string {
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "%d of %d tenants failed", len(e.Failed), e.Total)
+	for _, result := range e.Failed {
+		buf.WriteString("\n" + result.Tenant + ": " + result.Err.Error())
+	}
+	return buf.String()
+}
+
+// OpenTenant returns the database of a tenant, and a function that releases
+// it once its tables have been ensured.
+type OpenTenant func(tenant string) (db DB, release func() error, err error)
+
+// EnsureTenants calls EnsureTables with the same wantTables for every tenant,
+// running up to opts.Concurrency of them at once. The tables in wantTables
+// should not name a schema, so that they are created in whatever schema (or
+// database) openTenant points the tenant's DB at. Every tenant gets a result,
+// in the order of tenants, and the tenants that failed are also returned
+// together as a *TenantsError.
+func EnsureTenants(dialect string, wantTables WantTables, tenants []string, openTenant OpenTenant, opts TenantOptions) ([]TenantResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]TenantResult, len(tenants))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+	for i, tenant := range tenants {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed && opts.FailFast
+		mu.Unlock()
+		if stop {
+			<-sem
+			results[i] = TenantResult{Tenant: tenant, Skipped: true}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, tenant string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			err := ensureTenant(dialect, wantTables, tenant, openTenant)
+			results[i] = TenantResult{Tenant: tenant, Err: err}
+			if err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, tenant)
+	}
+	wg.Wait()
+	tenantsErr := &TenantsError{Total: len(tenants)}
+	for _, result := range results {
+		if result.Err != nil {
+			tenantsErr.Failed = append(tenantsErr.Failed, result)
+		}
+	}
+	if len(tenantsErr.Failed) > 0 {
+		return results, tenantsErr
+	}
+	return results, nil
+}
+
+func ensureTenant(dialect string, wantTables WantTables, tenant string, openTenant OpenTenant) error {
+	db, release, err := openTenant(tenant)
+	if err != nil {
+		return err
+	}
+	err = EnsureTables(db, dialect, wantTables)
+	if releaseErr := release(); releaseErr != nil && err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// SchemaTenants returns an OpenTenant that gives each tenant its own schema
+// (its own database on MySQL) within db. The schema is created if it does
+// not exist yet, and the tenant's tables are ensured on a dedicated
+// connection whose search_path (or current database) is set to it until the
+// connection is released back into the pool. On Postgres the search_path is
+// followed by sharedSchemas, or by public if none are given, so that shared
+// extensions, types and functions still resolve. MySQL can only use a single
+// database, so sharedSchemas are not supported there.
+func SchemaTenants(db *sql.DB, dialect string, sharedSchemas ...string) OpenTenant {
+	return func(tenant string) (DB, func() error, error) {
+		searchPathQuery, err := tenantSearchPathQuery(dialect, tenant, sharedSchemas)
+		if err != nil {
+			return nil, nil, err
+		}
+		ctx := context.Background()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		tenantDB := connDB{ctx: ctx, conn: conn}
+		var resetQuery string
+		if dialect == "mysql" {
+			var database string
+			err = tenantDB.QueryRow("SELECT COALESCE(DATABASE(), '')").Scan(&database)
+			if err != nil {
+				conn.Close()
+				return nil, nil, err
+			}
+			if database != "" {
+				resetQuery = "USE " + quoteIdentifier(dialect, database)
+			}
+		} else {
+			resetQuery = "RESET search_path"
+		}
+		for _, query := range []string{createSchemaQuery(dialect, tenant), searchPathQuery} {
+			_, err = tenantDB.Exec(query)
+			if err != nil {
+				conn.Close()
+				return nil, nil, fmt.Errorf("%s: %w", query, err)
+			}
+		}
+		release := func() error {
+			if resetQuery != "" {
+				_, err := tenantDB.Exec(resetQuery)
+				if err != nil {
+					conn.Close()
+					return fmt.Errorf("%s: %w", resetQuery, err)
+				}
+			}
+			return conn.Close()
+		}
+		return tenantDB, release, nil
+	}
+}
+
+// tenantSearchPathQuery returns the query that makes unqualified names
+// resolve to a tenant's schema, then to sharedSchemas (public by default) on
+// Postgres.
+func tenantSearchPathQuery(dialect string, tenant string, sharedSchemas []string) (string, error) {
+	switch dialect {
+	case "postgres":
+		if len(sharedSchemas) == 0 {
+			sharedSchemas = []string{"public"}
+		}
+		return SearchPathQuery(dialect, append([]string{tenant}, sharedSchemas...)...)
+	case "mysql":
+		if len(sharedSchemas) > 0 {
+			return "", fmt.Errorf("mysql: schema tenants cannot share databases")
+		}
+		return SearchPathQuery(dialect, tenant)
+	default:
+		return "", fmt.Errorf("%s: schema tenants are not supported, use a database per tenant", dialect)
+	}
+}
+
+// connDB adapts a *sql.Conn to DB, pinning every query to one connection.
+type connDB struct {
+	ctx  context.Context
+	conn *sql.Conn
+}
+
+func (db connDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.conn.ExecContext(db.ctx, query, args...)
+}
+
+func (db connDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.conn.QueryContext(db.ctx, query, args...)
+}
+
+func (db connDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.conn.QueryRowContext(db.ctx, query, args...)
+}
@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestEnsureTenants(t *testing.T) {
+	is := testutil.New(t)
+	dir := t.TempDir()
+	country := [2]string{"", "country"}
+	wantTables := mockTables{
+		tables:      [][2]string{country},
+		columns:     map[[2]string]map[string]Column{country: {"country_id": {TableName: "country", ColumnName: "country_id"}}},
+		createTable: map[[2]string][]string{country: {"CREATE TABLE country (country_id INTEGER PRIMARY KEY)"}},
+	}
+	openTenant := func(tenant string) (DB, func() error, error) {
+		if tenant == "tenant_3" {
+			return nil, nil, fmt.Errorf("no such tenant")
+		}
+		db, err := sql.Open("sqlite3", filepath.Join(dir, tenant+".db"))
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, db.Close, nil
+	}
+	var tenants []string
+	for i := 1; i <= 6; i++ {
+		tenants = append(tenants, fmt.Sprintf("tenant_%d", i))
+	}
+	results, err := EnsureTenants("sqlite3", wantTables, tenants, openTenant, TenantOptions{Concurrency: 3})
+	var tenantsErr *TenantsError
+	is.True(errors.As(err, &tenantsErr))
+	is.Equal(1, len(tenantsErr.Failed))
+	is.Equal("tenant_3", tenantsErr.Failed[0].Tenant)
+	is.Equal(len(tenants), len(results))
+	for i, result := range results {
+		is.Equal(tenants[i], result.Tenant)
+		is.True(!result.Skipped)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "tenant_6.db"))
+	is.NoErr(err)
+	defer db.Close()
+	columnNames, err := getColumnNames(db, "sqlite3", country)
+	is.NoErr(err)
+	is.True(columnNames["country_id"])
+
+	results, err = EnsureTenants("sqlite3", wantTables, tenants, openTenant, TenantOptions{FailFast: true})
+	is.True(err != nil)
+	is.True(results[3].Skipped && results[5].Skipped)
+	is.True(!results[1].Skipped)
+}
+
+func TestTenantSearchPathQuery(t *testing.T) {
+	is := testutil.New(t)
+	query, err := tenantSearchPathQuery("postgres", "tenant_1", nil)
+	is.NoErr(err)
+	is.Equal("SET search_path TO tenant_1, public", query)
+	query, err = tenantSearchPathQuery("postgres", "tenant_1", []string{"shared", "extensions"})
+	is.NoErr(err)
+	is.Equal("SET search_path TO tenant_1, shared, extensions", query)
+	query, err = tenantSearchPathQuery("mysql", "tenant_1", nil)
+	is.NoErr(err)
+	is.Equal("USE tenant_1", query)
+	_, err = tenantSearchPathQuery("mysql", "tenant_1", []string{"shared"})
+	is.True(err != nil)
+	_, err = tenantSearchPathQuery("sqlite3", "tenant_1", nil)
+	is.True(err != nil)
+}
@@ -0,0 +1,66 @@
+package metadata
+
+import "fmt"
+
+// onUpdateCurrentTimestampFunction is the trigger function shared by every
+// Postgres trigger emulating ON UPDATE CURRENT_TIMESTAMP. It takes the name of
+// the column to touch as its argument and leaves the column alone if the
+// UPDATE already changed it, like MySQL does.
+const onUpdateCurrentTimestampFunction = `CREATE OR REPLACE FUNCTION on_update_current_timestamp_trg() RETURNS trigger AS $$ BEGIN
+    IF to_jsonb(NEW)->TG_ARGV[0] IS NOT DISTINCT FROM to_jsonb(OLD)->TG_ARGV[0] THEN
+        NEW := jsonb_populate_record(NEW, jsonb_build_object(TG_ARGV[0], NOW()));
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql`
+
+// OnUpdateCurrentTimestampTriggers returns the triggers that emulate MySQL's
+// ON UPDATE CURRENT_TIMESTAMP for the columns that declare it. MySQL supports
+// it natively and needs no triggers. On Postgres each table gets a BEFORE
+// UPDATE trigger calling a shared trigger function, while on SQLite each
+// table gets an AFTER UPDATE trigger that updates the row again (this relies
+// on the table having a rowid).
+func OnUpdateCurrentTimestampTriggers(dialect string, columns []Column) []Trigger {
+	var triggers []Trigger
+	for _, column := range columns {
+		if !column.OnUpdateCurrentTimestamp.Valid || !column.OnUpdateCurrentTimestamp.Bool {
+			continue
+		}
+		trigger := Trigger{
+			TableSchema: column.TableSchema,
+			TableName:   column.TableName,
+			TriggerName: column.TableName + "_" + column.ColumnName + "_on_update_trg",
+			Events:      []string{"UPDATE"},
+		}
+		col := quoteIdentifier(dialect, column.ColumnName)
+		switch dialect {
+		case "postgres":
+			trigger.Timing = "BEFORE"
+			trigger.Body = "EXECUTE FUNCTION on_update_current_timestamp_trg(" + quoteLiteral(column.ColumnName) + ")"
+		case "sqlite3":
+			trigger.Timing = "AFTER"
+			trigger.Body = "WHEN NEW." + col + " IS OLD." + col + " BEGIN" +
+				" UPDATE " + quoteIdentifier(dialect, column.TableName) + " SET " + col + " = DATETIME('now') WHERE rowid = NEW.rowid;" +
+				" END"
+		default:
+			continue
+		}
+		triggers = append(triggers, trigger)
+	}
+	return triggers
+}
+
+// EnsureOnUpdateCurrentTimestamp ensures the triggers (and on Postgres, the
+// shared trigger function) returned by OnUpdateCurrentTimestampTriggers.
+func EnsureOnUpdateCurrentTimestamp(db DB, dialect string, columns []Column) error {
+	triggers := OnUpdateCurrentTimestampTriggers(dialect, columns)
+	if len(triggers) == 0 {
+		return nil
+	}
+	if dialect == "postgres" {
+		_, err := db.Exec(onUpdateCurrentTimestampFunction)
+		if err != nil {
+			return fmt.Errorf("on_update_current_timestamp_trg: %w", err)
+		}
+	}
+	return EnsureTriggers(db, dialect, triggers)
+}
@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestOnUpdateCurrentTimestamp(t *testing.T) {
+	is := testutil.New(t)
+	columns := []Column{
+		{TableName: "actor", ColumnName: "first_name"},
+		{TableName: "actor", ColumnName: "last_update", OnUpdateCurrentTimestamp: sql.NullBool{Bool: true, Valid: true}},
+	}
+	is.Equal(0, len(OnUpdateCurrentTimestampTriggers("mysql", columns)))
+	is.Equal([]Trigger{{
+		TableName:   "actor",
+		TriggerName: "actor_last_update_on_update_trg",
+		Timing:      "BEFORE",
+		Events:      []string{"UPDATE"},
+		Body:        "EXECUTE FUNCTION on_update_current_timestamp_trg('last_update')",
+	}}, OnUpdateCurrentTimestampTriggers("postgres", columns))
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE actor (actor_id INTEGER PRIMARY KEY, first_name TEXT, last_update TEXT)")
+	is.NoErr(err)
+	is.NoErr(EnsureOnUpdateCurrentTimestamp(db, "sqlite3", columns))
+	is.NoErr(EnsureOnUpdateCurrentTimestamp(db, "sqlite3", columns))
+	_, err = db.Exec("INSERT INTO actor (first_name, last_update) VALUES ('alice', 'never'), ('bob', 'never')")
+	is.NoErr(err)
+
+	_, err = db.Exec("UPDATE actor SET first_name = 'carol' WHERE actor_id = 1")
+	is.NoErr(err)
+	var lastUpdate string
+	err = db.QueryRow("SELECT last_update FROM actor WHERE actor_id = 1").Scan(&lastUpdate)
+	is.NoErr(err)
+	is.True(lastUpdate != "never")
+
+	_, err = db.Exec("UPDATE actor SET first_name = 'dave', last_update = 'explicit' WHERE actor_id = 2")
+	is.NoErr(err)
+	err = db.QueryRow("SELECT last_update FROM actor WHERE actor_id = 2").Scan(&lastUpdate)
+	is.NoErr(err)
+	is.Equal("explicit", lastUpdate)
+}
@@ -0,0 +1,223 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Trigger is a row-level trigger on a table. Body is everything that follows
+// FOR EACH ROW, which is an EXECUTE FUNCTION call on Postgres and a
+// BEGIN ... END block on MySQL and SQLite. MySQL and SQLite triggers can only
+// fire on a single event.
+type Trigger struct {
+	TableSchema string
+	TableName   string
+	TriggerName string
+	Timing      string   // BEFORE | AFTER | INSTEAD OF
+	Events      []string // INSERT | UPDATE | DELETE
+	Body        string
+}
+
+func createTriggerQuery(dialect string, trigger Trigger) (string, error) {
+	if trigger.TriggerName == "" {
+		return "", fmt.Errorf("trigger on table %s has no name", trigger.TableName)
+	}
+	if len(trigger.Events) == 0 {
+		return "", fmt.Errorf("trigger %s has no events", trigger.TriggerName)
+	}
+	if len(trigger.Events) > 1 && dialect != "postgres" {
+		return "", fmt.Errorf("%s: trigger %s can only fire on a single event", dialect, trigger.TriggerName)
+	}
+	timing := trigger.Timing
+	if timing == "" {
+		timing = "BEFORE"
+	}
+	name := quoteIdentifier(dialect, trigger.TriggerName)
+	if dialect == "sqlite3" && trigger.TableSchema != "" {
+		name = quoteIdentifier(dialect, trigger.TableSchema) + "." + name
+	}
+	return "CREATE TRIGGER " + name + " " + timing + " " + strings.Join(trigger.Events, " OR ") +
+		" ON " + qualifiedName(dialect, trigger.TableSchema, trigger.TableName) + " FOR EACH ROW " + trigger.Body, nil
+}
+
+func dropTriggerQuery(dialect string, trigger Trigger) string {
+	if dialect == "postgres" {
+		return "DROP TRIGGER IF EXISTS " + quoteIdentifier(dialect, trigger.TriggerName) + " ON " + qualifiedName(dialect, trigger.TableSchema, trigger.TableName)
+	}
+	if dialect == "mysql" && trigger.TableSchema != "" {
+		return "DROP TRIGGER IF EXISTS " + qualifiedName(dialect, trigger.TableSchema, trigger.TriggerName)
+	}
+	return "DROP TRIGGER IF EXISTS " + quoteIdentifier(dialect, trigger.TriggerName)
+}
+
+// triggerChanged reports whether gotTrigger has to be recreated to become
+// wantTrigger.
+func triggerChanged(gotTrigger, wantTrigger Trigger) bool {
+	wantTiming := wantTrigger.Timing
+	if wantTiming == "" {
+		wantTiming = "BEFORE"
+	}
+	if !strings.EqualFold(gotTrigger.Timing, wantTiming) {
+		return true
+	}
+	gotEvents := append([]string{}, gotTrigger.Events...)
+	wantEvents := append([]string{}, wantTrigger.Events...)
+	sort.Strings(gotEvents)
+	sort.Strings(wantEvents)
+	if !strings.EqualFold(strings.Join(gotEvents, ","), strings.Join(wantEvents, ",")) {
+		return true
+	}
+	return normalizeExpr(gotTrigger.Body) != normalizeExpr(wantTrigger.Body)
+}
+
+// GetTriggers returns the triggers on a table in the database.
+func GetTriggers(db DB, dialect string, tableName [2]string) (triggers map[string]Trigger, err error) {
+	switch dialect {
+	case "postgres":
+		return getPostgresTriggers(db, tableName)
+	case "mysql":
+		return getMySQLTriggers(db, tableName)
+	case "sqlite3":
+		return getSQLiteTriggers(db, tableName)
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
+
+func getPostgresTriggers(db DB, tableName [2]string) (map[string]Trigger, error) {
+	rows, err := db.Query(`SELECT t.tgname, t.tgtype, pg_get_triggerdef(t.oid)
+FROM pg_trigger AS t
+JOIN pg_class AS c ON c.oid = t.tgrelid
+JOIN pg_namespace AS n ON n.oid = c.relnamespace
+WHERE NOT t.tgisinternal AND n.nspname = COALESCE(NULLIF($1, ''), current_schema()) AND c.relname = $2`, tableName[0], tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	triggers := make(map[string]Trigger)
+	for rows.Next() {
+		trigger := Trigger{TableSchema: tableName[0], TableName: tableName[1]}
+		var tgtype int
+		var definition string
+		err = rows.Scan(&trigger.TriggerName, &tgtype, &definition)
+		if err != nil {
+			return nil, err
+		}
+		// https://github.com/postgres/postgres/blob/master/src/include/catalog/pg_trigger.h
+		switch {
+		case tgtype&64 != 0:
+			trigger.Timing = "INSTEAD OF"
+		case tgtype&2 != 0:
+			trigger.Timing = "BEFORE"
+		default:
+			trigger.Timing = "AFTER"
+		}
+		if tgtype&4 != 0 {
+			trigger.Events = append(trigger.Events, "INSERT")
+		}
+		if tgtype&8 != 0 {
+			trigger.Events = append(trigger.Events, "DELETE")
+		}
+		if tgtype&16 != 0 {
+			trigger.Events = append(trigger.Events, "UPDATE")
+		}
+		if i := strings.Index(definition, " FOR EACH ROW "); i >= 0 {
+			trigger.Body = definition[i+len(" FOR EACH ROW "):]
+		}
+		triggers[trigger.TriggerName] = trigger
+	}
+	return triggers, rows.Err()
+}
+
+func getMySQLTriggers(db DB, tableName [2]string) (map[string]Trigger, error) {
+	rows, err := db.Query(`SELECT TRIGGER_NAME, ACTION_TIMING, EVENT_MANIPULATION, ACTION_STATEMENT
+FROM information_schema.TRIGGERS
+WHERE EVENT_OBJECT_SCHEMA = COALESCE(NULLIF(?, ''), DATABASE()) AND EVENT_OBJECT_TABLE = ?`, tableName[0], tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	triggers := make(map[string]Trigger)
+	for rows.Next() {
+		trigger := Trigger{TableSchema: tableName[0], TableName: tableName[1]}
+		var event string
+		err = rows.Scan(&trigger.TriggerName, &trigger.Timing, &event, &trigger.Body)
+		if err != nil {
+			return nil, err
+		}
+		trigger.Events = []string{event}
+		triggers[trigger.TriggerName] = trigger
+	}
+	return triggers, rows.Err()
+}
+
+var sqliteTriggerRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:TEMP\s+|TEMPORARY\s+)?TRIGGER\s+(?:IF\s+NOT\s+EXISTS\s+)?\S+\s+(BEFORE\s+|AFTER\s+|INSTEAD\s+OF\s+)?(DELETE|INSERT|UPDATE)\s.*?\bON\s+\S+\s+(?:FOR\s+EACH\s+ROW\s+)?(.*)$`)
+
+func getSQLiteTriggers(db DB, tableName [2]string) (map[string]Trigger, error) {
+	rows, err := db.Query("SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND tbl_name = ?", tableName[1])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	triggers := make(map[string]Trigger)
+	for rows.Next() {
+		trigger := Trigger{TableSchema: tableName[0], TableName: tableName[1]}
+		var definition string
+		err = rows.Scan(&trigger.TriggerName, &definition)
+		if err != nil {
+			return nil, err
+		}
+		matches := sqliteTriggerRegexp.FindStringSubmatch(definition)
+		if matches == nil {
+			return nil, fmt.Errorf("unable to parse trigger %s: %s", trigger.TriggerName, definition)
+		}
+		trigger.Timing = strings.ToUpper(strings.Join(strings.Fields(matches[1]), " "))
+		if trigger.Timing == "" {
+			trigger.Timing = "BEFORE"
+		}
+		trigger.Events = []string{strings.ToUpper(matches[2])}
+		trigger.Body = matches[3]
+		triggers[trigger.TriggerName] = trigger
+	}
+	return triggers, rows.Err()
+}
+
+// EnsureTriggers creates the triggers that do not exist yet and recreates
+// the ones whose timing, events or body differ. Triggers in the database
+// that are not in triggers are left alone.
+func EnsureTriggers(db DB, dialect string, triggers []Trigger) error {
+	gotTriggersByTable := make(map[[2]string]map[string]Trigger)
+	for _, trigger := range triggers {
+		tableName := [2]string{trigger.TableSchema, trigger.TableName}
+		gotTriggers, ok := gotTriggersByTable[tableName]
+		if !ok {
+			var err error
+			gotTriggers, err = GetTriggers(db, dialect, tableName)
+			if err != nil {
+				return err
+			}
+			gotTriggersByTable[tableName] = gotTriggers
+		}
+		var querylist []string
+		if gotTrigger, ok := gotTriggers[trigger.TriggerName]; ok {
+			if !triggerChanged(gotTrigger, trigger) {
+				continue
+			}
+			querylist = append(querylist, dropTriggerQuery(dialect, trigger))
+		}
+		query, err := createTriggerQuery(dialect, trigger)
+		if err != nil {
+			return err
+		}
+		querylist = append(querylist, query)
+		for _, query := range querylist {
+			_, err = db.Exec(query)
+			if err != nil {
+				return fmt.Errorf("%s: %w", query, err)
+			}
+		}
+	}
+	return nil
+}
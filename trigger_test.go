@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestEnsureTriggers(t *testing.T) {
+	is := testutil.New(t)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE actor (actor_id INTEGER PRIMARY KEY, name TEXT, last_update TEXT)")
+	is.NoErr(err)
+	trigger := Trigger{
+		TableName:   "actor",
+		TriggerName: "actor_last_updated_after_update_trg",
+		Timing:      "AFTER",
+		Events:      []string{"UPDATE"},
+		Body:        "BEGIN UPDATE actor SET last_update = 'old' WHERE actor_id = NEW.actor_id; END",
+	}
+	is.NoErr(EnsureTriggers(db, "sqlite3", []Trigger{trigger}))
+	triggers, err := GetTriggers(db, "sqlite3", [2]string{"", "actor"})
+	is.NoErr(err)
+	is.Equal(map[string]Trigger{trigger.TriggerName: trigger}, triggers)
+
+	trigger.Body = "BEGIN UPDATE actor SET last_update = 'new' WHERE actor_id = NEW.actor_id; END"
+	is.True(triggerChanged(triggers[trigger.TriggerName], trigger))
+	is.NoErr(EnsureTriggers(db, "sqlite3", []Trigger{trigger}))
+	_, err = db.Exec("INSERT INTO actor (name) VALUES ('alice')")
+	is.NoErr(err)
+	_, err = db.Exec("UPDATE actor SET name = 'bob'")
+	is.NoErr(err)
+	var lastUpdate string
+	err = db.QueryRow("SELECT last_update FROM actor").Scan(&lastUpdate)
+	is.NoErr(err)
+	is.Equal("new", lastUpdate)
+
+	_, err = createTriggerQuery("mysql", Trigger{TableName: "actor", TriggerName: "trg", Events: []string{"INSERT", "UPDATE"}})
+	is.True(err != nil)
+	query, err := createTriggerQuery("postgres", Trigger{
+		TableSchema: "public",
+		TableName:   "film",
+		TriggerName: "film_fulltext_before_insert_update_trg",
+		Events:      []string{"INSERT", "UPDATE"},
+		Body:        "EXECUTE FUNCTION tsvector_update_trigger('fulltext', 'pg_catalog.english', 'title', 'description')",
+	})
+	is.NoErr(err)
+	is.Equal("CREATE TRIGGER film_fulltext_before_insert_update_trg BEFORE INSERT OR UPDATE ON public.film FOR EACH ROW EXECUTE FUNCTION tsvector_update_trigger('fulltext', 'pg_catalog.english', 'title', 'description')", query)
+}
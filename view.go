@@ -0,0 +1,333 @@
+package metadata
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// View is a view and the SELECT query that defines it, which is usually
+// different for each dialect. DependsOn lists the tables and views that the
+// query reads from, and is used to create views in dependency order and to
+// work out which views have to be dropped when a table changes.
+type View struct {
+	ViewSchema string
+	ViewName   string
+	Query      string
+	DependsOn  [][2]string
+}
+
+func createViewQuery(dialect string, view View) string {
+	return "CREATE VIEW " + qualifiedName(dialect, view.ViewSchema, view.ViewName) + " AS " + strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(view.Query), ";"))
+}
+
+func dropViewQuery(dialect string, view View) string {
+	return "DROP VIEW IF EXISTS " + qualifiedName(dialect, view.ViewSchema, view.ViewName)
+}
+
+// sortViews orders views so that every view comes after the views it depends
+// on. Unlike tables, views cannot depend on each other in a cycle.
+func sortViews(views []View) ([]View, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	byName := make(map[[2]string]View)
+	state := make(map[[2]string]int)
+	for _, view := range views {
+		byName[[2]string{view.ViewSchema, view.ViewName}] = view
+	}
+	var sorted []View
+	var visit func(view View) error
+	visit = func(view View) error {
+		viewName := [2]string{view.ViewSchema, view.ViewName}
+		state[viewName] = visiting
+		for _, dependency := range view.DependsOn {
+			dependencyView, ok := byName[dependency]
+			if !ok {
+				continue
+			}
+			switch state[dependency] {
+			case visiting:
+				return fmt.Errorf("views %s and %s depend on each other", view.ViewName, dependency[1])
+			case unvisited:
+				err := visit(dependencyView)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state[viewName] = visited
+		sorted = append(sorted, view)
+		return nil
+	}
+	for _, view := range views {
+		if state[[2]string{view.ViewSchema, view.ViewName}] != unvisited {
+			continue
+		}
+		err := visit(view)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// dependentViews returns the views that read from any of names, directly or
+// through another view, in dependency order.
+func dependentViews(sortedViews []View, names [][2]string) []View {
+	affected := make(map[[2]string]bool)
+	for _, name := range names {
+		affected[name] = true
+	}
+	var dependents []View
+	for _, view := range sortedViews {
+		for _, dependency := range view.DependsOn {
+			if affected[dependency] {
+				affected[[2]string{view.ViewSchema, view.ViewName}] = true
+				dependents = append(dependents, view)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+var sqliteViewRegexp = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:TEMP\s+|TEMPORARY\s+)?VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?\S+(?:\s*\([^)]*\))?\s+AS\s+(.*)$`)
+
+// GetViews returns the views in the database, keyed by their schema (or
+// database, for MySQL) and name. SQLite views belong to the main schema.
+func GetViews(db DB, dialect string) (views map[[2]string]View, err error) {
+	var query string
+	switch dialect {
+	case "postgres":
+		query = "SELECT schemaname, viewname, definition FROM pg_views" +
+			" WHERE schemaname NOT IN ('pg_catalog', 'information_schema')"
+	case "mysql":
+		query = "SELECT TABLE_SCHEMA, TABLE_NAME, VIEW_DEFINITION FROM information_schema.VIEWS" +
+			" WHERE TABLE_SCHEMA NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')"
+	case "sqlite3":
+		query = "SELECT 'main', name, sql FROM sqlite_master WHERE type = 'view'"
+	default:
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	views = make(map[[2]string]View)
+	for rows.Next() {
+		var view View
+		err = rows.Scan(&view.ViewSchema, &view.ViewName, &view.Query)
+		if err != nil {
+			return nil, err
+		}
+		if dialect == "sqlite3" {
+			matches := sqliteViewRegexp.FindStringSubmatch(view.Query)
+			if matches == nil {
+				return nil, fmt.Errorf("unable to parse view %s: %s", view.ViewName, view.Query)
+			}
+			view.Query = matches[1]
+		}
+		views[[2]string{view.ViewSchema, view.ViewName}] = view
+	}
+	return views, rows.Err()
+}
+
+// normalizeViewQuery normalizes a view's query so that the declared query
+// compares equal to the one the database reports back, without creating the
+// view. Postgres and MySQL qualify every column with its table and MySQL
+// aliases every column to itself, so self-aliases and the schema of the
+// tables read from are dropped. A column qualifier is dropped when the query
+// reads from a single table, and otherwise kept as the alias (or name) of the
+// table it refers to. String literals are left alone.
+func normalizeViewQuery(query string) string {
+	tokens := exprTokens(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	var names [][]string // the parts of each qualified name, nil otherwise
+	var merged []string
+	for i := 0; i < len(tokens); i++ {
+		if !isViewNameToken(tokens[i]) {
+			merged = append(merged, tokens[i])
+			names = append(names, nil)
+			continue
+		}
+		name := splitViewName(tokens[i])
+		for i+2 < len(tokens) && tokens[i+1] == "." && isViewNameToken(tokens[i+2]) {
+			name = append(name, splitViewName(tokens[i+2])...)
+			i += 2
+		}
+		merged = append(merged, strings.Join(name, "."))
+		names = append(names, name)
+	}
+	// Find the tables read from and their aliases.
+	isSource := make(map[int]bool)
+	isAliasAs := make(map[int]bool)
+	qualifiers := make(map[string]string)
+	sourceCount := 0
+	inFrom, expectSource := false, false
+	for i := 0; i < len(merged); i++ {
+		switch token := merged[i]; {
+		case token == "from" || token == "join":
+			inFrom, expectSource = true, true
+		case token == "," && inFrom:
+			expectSource = true
+		case token == "(":
+		case viewClauseKeywords[token]:
+			inFrom, expectSource = false, false
+		case expectSource && names[i] != nil:
+			expectSource = false
+			isSource[i] = true
+			sourceCount++
+			table := names[i][len(names[i])-1]
+			j := i + 1
+			if j < len(merged) && merged[j] == "as" {
+				isAliasAs[j] = true
+				j++
+			}
+			if j < len(merged) && names[j] != nil && len(names[j]) == 1 && !viewClauseKeywords[merged[j]] {
+				qualifiers[merged[j]] = merged[j]
+				i = j
+			} else {
+				delete(isAliasAs, i+1)
+				qualifiers[table] = table
+			}
+		default:
+			expectSource = false
+		}
+	}
+	var result []string
+	for i := 0; i < len(merged); i++ {
+		name := names[i]
+		switch {
+		case isAliasAs[i]:
+			continue
+		case isSource[i]:
+			result = append(result, name[len(name)-1])
+			continue
+		case len(name) >= 2:
+			column := name[len(name)-1]
+			if qualifier, ok := qualifiers[name[len(name)-2]]; ok {
+				if sourceCount == 1 {
+					result = append(result, column)
+				} else {
+					result = append(result, qualifier+"."+column)
+				}
+			} else {
+				result = append(result, merged[i])
+			}
+		default:
+			result = append(result, merged[i])
+		}
+		if i+2 < len(merged) && merged[i+1] == "as" && name != nil && names[i+2] != nil && merged[i+2] == name[len(name)-1] {
+			i += 2
+		}
+	}
+	return normalizeExpr(strings.Join(result, " "))
+}
+
+// viewClauseKeywords are the keywords that end a list of tables read from or
+// cannot be a table alias.
+var viewClauseKeywords = map[string]bool{
+	"select": true, "where": true, "group": true, "having": true, "order": true,
+	"limit": true, "offset": true, "union": true, "intersect": true, "except": true,
+	"on": true, "using": true, "window": true, "join": true, "inner": true,
+	"left": true, "right": true, "full": true, "cross": true, "natural": true,
+	"outer": true, "lateral": true, "fetch": true, "for": true, ")": true,
+}
+
+// isViewNameToken reports whether an exprTokens token is an identifier (and
+// not a string literal or a number).
+func isViewNameToken(token string) bool {
+	c := token[0]
+	return c == '"' || c == '`' || (isIdentifierByte(c) && (c < '0' || c > '9'))
+}
+
+func splitViewName(token string) []string {
+	if token[0] == '"' || token[0] == '`' {
+		return []string{token}
+	}
+	return strings.Split(strings.Trim(token, "."), ".")
+}
+
+// DropDependentViews drops the views that read from any of tableNames,
+// directly or through another view, so that the tables can be altered. The
+// dropped views are returned and should be passed to EnsureViews afterwards
+// to recreate them.
+func DropDependentViews(db DB, dialect string, views []View, tableNames [][2]string) (dropped []View, err error) {
+	sortedViews, err := sortViews(views)
+	if err != nil {
+		return nil, err
+	}
+	dependents := dependentViews(sortedViews, tableNames)
+	for i := len(dependents) - 1; i >= 0; i-- {
+		query := dropViewQuery(dialect, dependents[i])
+		_, err = db.Exec(query)
+		if err != nil {
+			return dropped, fmt.Errorf("%s: %w", query, err)
+		}
+		dropped = append(dropped, dependents[i])
+	}
+	return dropped, nil
+}
+
+// EnsureViews creates the views that do not exist yet and recreates the ones
+// whose query differs, together with every view that depends on them. It
+// should be called after the tables have been ensured.
+func EnsureViews(db DB, dialect string, views []View) error {
+	sortedViews, err := sortViews(views)
+	if err != nil {
+		return err
+	}
+	gotViews, err := GetViews(db, dialect)
+	if err != nil {
+		return err
+	}
+	defaultSchema, err := DefaultSchema(db, dialect)
+	if err != nil {
+		return err
+	}
+	lookupView := func(view View) (View, bool) {
+		schema := view.ViewSchema
+		if schema == "" {
+			schema = defaultSchema
+		}
+		gotView, ok := gotViews[[2]string{schema, view.ViewName}]
+		return gotView, ok
+	}
+	var changed [][2]string
+	for _, view := range sortedViews {
+		viewName := [2]string{view.ViewSchema, view.ViewName}
+		gotView, ok := lookupView(view)
+		if !ok || normalizeViewQuery(view.Query) != normalizeViewQuery(gotView.Query) {
+			changed = append(changed, viewName)
+		}
+	}
+	isChanged := make(map[[2]string]bool)
+	for _, viewName := range changed {
+		isChanged[viewName] = true
+	}
+	for _, view := range dependentViews(sortedViews, changed) {
+		isChanged[[2]string{view.ViewSchema, view.ViewName}] = true
+	}
+	var querylist []string
+	for i := len(sortedViews) - 1; i >= 0; i-- {
+		view := sortedViews[i]
+		if _, ok := lookupView(view); ok && isChanged[[2]string{view.ViewSchema, view.ViewName}] {
+			querylist = append(querylist, dropViewQuery(dialect, view))
+		}
+	}
+	for _, view := range sortedViews {
+		if isChanged[[2]string{view.ViewSchema, view.ViewName}] {
+			querylist = append(querylist, createViewQuery(dialect, view))
+		}
+	}
+	for _, query := range querylist {
+		_, err = db.Exec(query)
+		if err != nil {
+			return fmt.Errorf("%s: %w", query, err)
+		}
+	}
+	return nil
+}
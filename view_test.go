@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/bokwoon95/testutil"
+)
+
+func TestEnsureViews(t *testing.T) {
+	is := testutil.New(t)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE actor (actor_id INTEGER PRIMARY KEY, first_name TEXT, last_name TEXT)")
+	is.NoErr(err)
+	_, err = db.Exec("INSERT INTO actor (first_name, last_name) VALUES ('PENELOPE', 'GUINESS')")
+	is.NoErr(err)
+	actorList := View{
+		ViewName:  "actor_list",
+		Query:     "SELECT actor_id AS id, first_name || ' ' || last_name AS name FROM actor;",
+		DependsOn: [][2]string{{"", "actor"}},
+	}
+	actorNames := View{
+		ViewName:  "actor_names",
+		Query:     "SELECT name FROM actor_list",
+		DependsOn: [][2]string{{"", "actor_list"}},
+	}
+	views := []View{actorNames, actorList}
+	sortedViews, err := sortViews(views)
+	is.NoErr(err)
+	is.Equal([]View{actorList, actorNames}, sortedViews)
+
+	is.NoErr(EnsureViews(db, "sqlite3", views))
+	is.NoErr(EnsureViews(db, "sqlite3", views))
+	var name string
+	is.NoErr(db.QueryRow("SELECT name FROM actor_names").Scan(&name))
+	is.Equal("PENELOPE GUINESS", name)
+
+	views[1].Query = "SELECT actor_id AS id, last_name || ', ' || first_name AS name FROM actor"
+	is.NoErr(EnsureViews(db, "sqlite3", views))
+	is.NoErr(db.QueryRow("SELECT name FROM actor_names").Scan(&name))
+	is.Equal("GUINESS, PENELOPE", name)
+
+	dropped, err := DropDependentViews(db, "sqlite3", views, [][2]string{{"", "actor"}})
+	is.NoErr(err)
+	is.Equal(2, len(dropped))
+	is.Equal("actor_names", dropped[0].ViewName)
+	gotViews, err := GetViews(db, "sqlite3")
+	is.NoErr(err)
+	is.Equal(0, len(gotViews))
+	is.NoErr(EnsureViews(db, "sqlite3", dropped))
+	gotViews, err = GetViews(db, "sqlite3")
+	is.NoErr(err)
+	is.Equal(2, len(gotViews))
+
+	_, err = sortViews([]View{
+		{ViewName: "a", DependsOn: [][2]string{{"", "b"}}},
+		{ViewName: "b", DependsOn: [][2]string{{"", "a"}}},
+	})
+	is.True(err != nil)
+}
+
+func TestNormalizeViewQuery(t *testing.T) {
+	is := testutil.New(t)
+	want := normalizeViewQuery("SELECT actor_id AS id, first_name || ' ' || last_name AS name FROM actor WHERE actor_id > 1;")
+	is.Equal(want, normalizeViewQuery(" SELECT actor.actor_id AS id,\n    ((actor.first_name || ' '::text) || actor.last_name) AS name\n   FROM actor\n  WHERE (actor.actor_id > 1);"))
+	is.Equal(
+		normalizeViewQuery("SELECT actor_id, last_name FROM actor"),
+		normalizeViewQuery("select `db`.`actor`.`actor_id` AS `actor_id`,`db`.`actor`.`last_name` AS `last_name` from `db`.`actor`"),
+	)
+	is.True(want != normalizeViewQuery("SELECT actor_id AS id, last_name AS name FROM actor WHERE actor_id > 1"))
+
+	is.True(normalizeViewQuery("SELECT email FROM customer WHERE email LIKE '%@example.com'") !=
+		normalizeViewQuery("SELECT email FROM customer WHERE email LIKE '%@sample.com'"))
+
+	want = normalizeViewQuery("SELECT a.actor_id, b.film_id FROM actor AS a JOIN film_actor AS b ON a.actor_id = b.actor_id")
+	is.Equal(want, normalizeViewQuery(" SELECT a.actor_id,\n    b.film_id\n   FROM (actor a\n     JOIN film_actor b ON ((a.actor_id = b.actor_id)));"))
+	is.Equal(want, normalizeViewQuery("select `a`.`actor_id` AS `actor_id`,`b`.`film_id` AS `film_id` from (`db`.`actor` `a` join `db`.`film_actor` `b` on((`a`.`actor_id` = `b`.`actor_id`)))"))
+	is.True(want != normalizeViewQuery("SELECT b.actor_id, a.film_id FROM actor AS a JOIN film_actor AS b ON a.actor_id = b.actor_id"))
+}
+
+func TestEnsureViewsDefaultSchema(t *testing.T) {
+	is := testutil.New(t)
+	db, err := sql.Open("sqlite3", ":memory:")
+	is.NoErr(err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE actor (actor_id INTEGER PRIMARY KEY, last_name TEXT)")
+	is.NoErr(err)
+	views := []View{{ViewName: "actor_names", Query: "SELECT last_name FROM actor"}}
+	is.NoErr(EnsureViews(db, "sqlite3", views))
+	views[0].ViewSchema = "main"
+	is.NoErr(EnsureViews(db, "sqlite3", views))
+	gotViews, err := GetViews(db, "sqlite3")
+	is.NoErr(err)
+	is.Equal(1, len(gotViews))
+	is.Equal("SELECT last_name FROM actor", gotViews[[2]string{"main", "actor_names"}].Query)
+}